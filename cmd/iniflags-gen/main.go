@@ -0,0 +1,478 @@
+// Command iniflags-gen generates flag registrations from a YAML flag
+// definition file.
+//
+// Usage with go:generate:
+//
+//	//go:generate go run github.com/boomhut/iniflags/cmd/iniflags-gen -in=flags.yaml -out=flags_gen.go
+//
+// The definition file has the following format:
+//
+//	package: main
+//	flags:
+//	  - name: addr
+//	    type: string
+//	    default: ":8080"
+//	    usage: TCP address to listen to
+//	    shorthand: a
+//	  - name: dbPassword
+//	    usage: Database password
+//	    secret: true
+//
+// Supported flag fields are name, var (Go variable name; derived from name
+// by default), type (string by default), default, usage, shorthand,
+// commandLineShorthand and secret. Supported types are string, bool, int,
+// int64, uint, uint64, float64, duration and stringSlice. Defaults for
+// stringSlice are lists in the form accepted by iniflags, e.g. a, "b,c".
+// Variable names must be valid Go identifiers, which aren't keywords,
+// and must be unique.
+//
+// Only the YAML subset shown above is supported: scalar values, which may be
+// quoted, and a single list of flag mappings.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	inPath  = flag.String("in", "flags.yaml", "Path to the flag definition file")
+	outPath = flag.String("out", "flags_gen.go", "Path to the generated Go file")
+)
+
+func main() {
+	flag.Parse()
+	src, err := os.ReadFile(*inPath)
+	if err != nil {
+		log.Fatalf("iniflags-gen: cannot read flag definitions: %s", err)
+	}
+	defs, err := parseDefinitions(bytes.NewReader(src))
+	if err != nil {
+		log.Fatalf("iniflags-gen: cannot parse flag definitions at [%s]: %s", *inPath, err)
+	}
+	code, err := generate(defs, filepath.Base(*inPath))
+	if err != nil {
+		log.Fatalf("iniflags-gen: cannot generate code for [%s]: %s", *inPath, err)
+	}
+	if err := os.WriteFile(*outPath, code, 0644); err != nil {
+		log.Fatalf("iniflags-gen: cannot write generated code: %s", err)
+	}
+}
+
+type definitions struct {
+	Package string
+	Flags   []flagDef
+}
+
+type flagDef struct {
+	Name                 string
+	Var                  string
+	Type                 string
+	Default              string
+	Usage                string
+	Shorthand            string
+	CommandLineShorthand string
+	Secret               bool
+
+	lineNum int
+}
+
+// parseDefinitions parses flag definitions in the YAML subset described
+// in the package docs.
+func parseDefinitions(r io.Reader) (*definitions, error) {
+	defs := &definitions{}
+	var inFlags bool
+	var fd *flagDef
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '-' {
+			// top-level key
+			key, value, err := splitKeyValue(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("%s at line %d", err, lineNum)
+			}
+			inFlags = false
+			switch key {
+			case "package":
+				defs.Package = value
+			case "flags":
+				if value != "" {
+					return nil, fmt.Errorf("flags must be a list at line %d", lineNum)
+				}
+				inFlags = true
+			default:
+				return nil, fmt.Errorf("unknown key [%s] at line %d", key, lineNum)
+			}
+			continue
+		}
+		if !inFlags {
+			return nil, fmt.Errorf("unexpected indented line at line %d", lineNum)
+		}
+		if strings.HasPrefix(trimmed, "-") {
+			defs.Flags = append(defs.Flags, flagDef{lineNum: lineNum})
+			fd = &defs.Flags[len(defs.Flags)-1]
+			trimmed = strings.TrimSpace(trimmed[1:])
+			if trimmed == "" {
+				continue
+			}
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("missing '-' before the flag definition at line %d", lineNum)
+		}
+		key, value, err := splitKeyValue(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("%s at line %d", err, lineNum)
+		}
+		if err := fd.set(key, value); err != nil {
+			return nil, fmt.Errorf("%s at line %d", err, lineNum)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if defs.Package == "" {
+		defs.Package = "main"
+	}
+	return defs, nil
+}
+
+func splitKeyValue(line string) (string, string, error) {
+	n := strings.IndexByte(line, ':')
+	if n < 0 {
+		return "", "", fmt.Errorf("cannot find ':' in [%s]", line)
+	}
+	key := strings.TrimSpace(line[:n])
+	value, err := unquoteValue(strings.TrimSpace(line[n+1:]))
+	if err != nil {
+		return "", "", fmt.Errorf("cannot parse value for [%s]: %s", key, err)
+	}
+	return key, value, nil
+}
+
+// unquoteValue returns the scalar value with quotes or trailing comment
+// removed.
+func unquoteValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch value[0] {
+	case '"':
+		n := strings.LastIndexByte(value, '"')
+		if n == 0 {
+			return "", fmt.Errorf("missing closing quote in [%s]", value)
+		}
+		return strconv.Unquote(value[:n+1])
+	case '\'':
+		n := strings.LastIndexByte(value, '\'')
+		if n == 0 {
+			return "", fmt.Errorf("missing closing quote in [%s]", value)
+		}
+		return strings.ReplaceAll(value[1:n], "''", "'"), nil
+	}
+	if n := strings.Index(value, " #"); n >= 0 {
+		value = strings.TrimSpace(value[:n])
+	}
+	return value, nil
+}
+
+func (fd *flagDef) set(key, value string) error {
+	switch key {
+	case "name":
+		fd.Name = value
+	case "var":
+		fd.Var = value
+	case "type":
+		fd.Type = value
+	case "default":
+		fd.Default = value
+	case "usage":
+		fd.Usage = value
+	case "shorthand":
+		fd.Shorthand = value
+	case "commandLineShorthand":
+		fd.CommandLineShorthand = value
+	case "secret":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("cannot parse secret=[%s]: %s", value, err)
+		}
+		fd.Secret = b
+	default:
+		return fmt.Errorf("unknown flag field [%s]", key)
+	}
+	return nil
+}
+
+// generate returns formatted Go code registering the defined flags.
+func generate(defs *definitions, source string) ([]byte, error) {
+	var decls, inits bytes.Buffer
+	usesFlag := false
+	usesTime := false
+	usesIniflags := false
+	varFlags := make(map[string]*flagDef)
+	for i := range defs.Flags {
+		fd := &defs.Flags[i]
+		if fd.Name == "" {
+			return nil, fmt.Errorf("missing name for the flag defined at line %d", fd.lineNum)
+		}
+		if fd.Var == "" {
+			fd.Var = varName(fd.Name)
+		}
+		if err := checkVarName(fd.Var); err != nil {
+			return nil, fmt.Errorf("flag [%s] defined at line %d: %s; set another name via var", fd.Name, fd.lineNum, err)
+		}
+		if prev := varFlags[fd.Var]; prev != nil {
+			return nil, fmt.Errorf("flag [%s] defined at line %d and flag [%s] defined at line %d have the same Go variable name [%s]; set another name via var",
+				prev.Name, prev.lineNum, fd.Name, fd.lineNum, fd.Var)
+		}
+		varFlags[fd.Var] = fd
+		if fd.Type == "" {
+			fd.Type = "string"
+		}
+		ctor, defValue, err := flagConstructor(fd)
+		if err != nil {
+			return nil, fmt.Errorf("flag [%s] defined at line %d: %s", fd.Name, fd.lineNum, err)
+		}
+		if strings.HasPrefix(ctor, "flag.") {
+			usesFlag = true
+		} else {
+			usesIniflags = true
+		}
+		if strings.Contains(defValue, "time.") {
+			usesTime = true
+		}
+		fmt.Fprintf(&decls, "\t%s = %s(%q, %s, %q)\n", fd.Var, ctor, fd.Name, defValue, fd.Usage)
+
+		if fd.Shorthand != "" {
+			usesIniflags = true
+			fmt.Fprintf(&inits, "\tif err := iniflags.RegisterShorthand(%q, %q); err != nil {\n\t\tpanic(err)\n\t}\n", fd.Shorthand, fd.Name)
+		}
+		if fd.CommandLineShorthand != "" {
+			usesIniflags = true
+			fmt.Fprintf(&inits, "\tif err := iniflags.RegisterCommandLineShorthand(%q, %q); err != nil {\n\t\tpanic(err)\n\t}\n", fd.CommandLineShorthand, fd.Name)
+		}
+		if fd.Secret {
+			usesIniflags = true
+			fmt.Fprintf(&inits, "\tiniflags.MarkSecret(%q)\n", fd.Name)
+		}
+	}
+
+	var bb bytes.Buffer
+	fmt.Fprintf(&bb, "// Code generated by iniflags-gen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&bb, "package %s\n\n", defs.Package)
+	fmt.Fprintf(&bb, "import (\n")
+	if usesFlag {
+		fmt.Fprintf(&bb, "\t\"flag\"\n")
+	}
+	if usesTime {
+		fmt.Fprintf(&bb, "\t\"time\"\n")
+	}
+	if usesIniflags {
+		fmt.Fprintf(&bb, "\n\t\"github.com/boomhut/iniflags\"\n")
+	}
+	fmt.Fprintf(&bb, ")\n\n")
+	fmt.Fprintf(&bb, "var (\n%s)\n", decls.String())
+	if inits.Len() > 0 {
+		fmt.Fprintf(&bb, "\nfunc init() {\n%s}\n", inits.String())
+	}
+	return format.Source(bb.Bytes())
+}
+
+var exportedTypeNames = map[string]string{
+	"int":    "Int",
+	"int64":  "Int64",
+	"uint":   "Uint",
+	"uint64": "Uint64",
+}
+
+// flagConstructor returns the function registering the flag and the Go
+// expression for the flag default value.
+func flagConstructor(fd *flagDef) (string, string, error) {
+	var err error
+	switch fd.Type {
+	case "string":
+		return "flag.String", strconv.Quote(fd.Default), nil
+	case "bool":
+		b := false
+		if fd.Default != "" {
+			b, err = strconv.ParseBool(fd.Default)
+		}
+		return "flag.Bool", strconv.FormatBool(b), err
+	case "int", "int64":
+		n := int64(0)
+		if fd.Default != "" {
+			n, err = strconv.ParseInt(fd.Default, 0, 64)
+		}
+		return "flag." + exportedTypeNames[fd.Type], strconv.FormatInt(n, 10), err
+	case "uint", "uint64":
+		n := uint64(0)
+		if fd.Default != "" {
+			n, err = strconv.ParseUint(fd.Default, 0, 64)
+		}
+		return "flag." + exportedTypeNames[fd.Type], strconv.FormatUint(n, 10), err
+	case "float64":
+		f := 0.0
+		if fd.Default != "" {
+			f, err = strconv.ParseFloat(fd.Default, 64)
+		}
+		return "flag.Float64", strconv.FormatFloat(f, 'g', -1, 64), err
+	case "duration":
+		d := time.Duration(0)
+		if fd.Default != "" {
+			d, err = time.ParseDuration(fd.Default)
+		}
+		return "flag.Duration", durationExpr(d), err
+	case "stringSlice":
+		if fd.Default == "" {
+			return "iniflags.StringSlice", "nil", nil
+		}
+		items, err := parseList(fd.Default)
+		if err != nil {
+			return "", "", err
+		}
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			quoted[i] = strconv.Quote(item)
+		}
+		return "iniflags.StringSlice", "[]string{" + strings.Join(quoted, ", ") + "}", nil
+	default:
+		return "", "", fmt.Errorf("unsupported type [%s]", fd.Type)
+	}
+}
+
+// durationExpr returns Go expression for d, e.g. 5 * time.Second.
+func durationExpr(d time.Duration) string {
+	units := []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+	if d == 0 {
+		return "0"
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return fmt.Sprintf("%d * %s", d/u.d, u.name)
+		}
+	}
+	return fmt.Sprintf("time.Duration(%d)", int64(d))
+}
+
+// parseList parses the list default value in the same way as iniflags
+// parses stringSlice values, e.g. "a, b", "[a, b]" or "\"a,b\", c".
+//
+// The generator doesn't import iniflags, since iniflags registers its own
+// flags on the command line of the generator.
+func parseList(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	if s == "" {
+		return []string{}, nil
+	}
+
+	var items []string
+	var item []byte
+	keep := 0 // length of the item prefix, which mustn't be trimmed
+	flush := func() {
+		for len(item) > keep && (item[len(item)-1] == ' ' || item[len(item)-1] == '\t') {
+			item = item[:len(item)-1]
+		}
+		items = append(items, string(item))
+		item = item[:0]
+		keep = 0
+	}
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(s):
+			i++
+			item = append(item, s[i])
+		case c == '"':
+			quoted = !quoted
+			keep = len(item)
+		case c == ',' && !quoted:
+			flush()
+		case !quoted && len(item) == 0 && (c == ' ' || c == '\t'):
+			// skip leading whitespace
+		default:
+			item = append(item, c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unclosed quote in the list [%s]", s)
+	}
+	flush()
+	return items, nil
+}
+
+// reservedNames contains identifiers, which cannot be used as variable names
+// in the generated code.
+var reservedNames = map[string]bool{
+	"flag":     true,
+	"time":     true,
+	"iniflags": true,
+	"init":     true,
+}
+
+// checkVarName verifies whether name may be used as Go variable name
+// in the generated code.
+func checkVarName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("cannot derive Go variable name")
+	case token.IsKeyword(name):
+		return fmt.Errorf("variable name [%s] is a Go keyword", name)
+	case !token.IsIdentifier(name):
+		return fmt.Errorf("[%s] isn't a valid Go variable name", name)
+	case reservedNames[name]:
+		return fmt.Errorf("variable name [%s] conflicts with the name used in the generated code", name)
+	}
+	return nil
+}
+
+// varName converts flag name such as "db.max-conns" to Go identifier
+// such as "dbMaxConns".
+func varName(name string) string {
+	var sb strings.Builder
+	upper := false
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = sb.Len() > 0
+			continue
+		}
+		if sb.Len() == 0 && unicode.IsDigit(c) {
+			sb.WriteByte('_')
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
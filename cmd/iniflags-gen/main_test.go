@@ -0,0 +1,222 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	src := `# flags for the app
+package: app
+flags:
+  - name: addr
+    default: ":8080"
+    usage: TCP address to listen to
+    shorthand: a
+  - name: db.max-conns
+    type: int
+    default: 10
+    usage: 'Maximum number of connections to the ''db'''
+  - name: timeout
+    type: duration
+    default: 1m30s
+    commandLineShorthand: t
+  - name: dbPassword
+    secret: true
+  - name: paths
+    type: stringSlice
+    default: /a, /b
+`
+	defs, err := parseDefinitions(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Cannot parse definitions: %s", err)
+	}
+	code, err := generate(defs, "flags.yaml")
+	if err != nil {
+		t.Fatalf("Cannot generate code: %s", err)
+	}
+	expected := `// Code generated by iniflags-gen from flags.yaml. DO NOT EDIT.
+
+package app
+
+import (
+	"flag"
+	"time"
+
+	"github.com/boomhut/iniflags"
+)
+
+var (
+	addr       = flag.String("addr", ":8080", "TCP address to listen to")
+	dbMaxConns = flag.Int("db.max-conns", 10, "Maximum number of connections to the 'db'")
+	timeout    = flag.Duration("timeout", 90*time.Second, "")
+	dbPassword = flag.String("dbPassword", "", "")
+	paths      = iniflags.StringSlice("paths", []string{"/a", "/b"}, "")
+)
+
+func init() {
+	if err := iniflags.RegisterShorthand("a", "addr"); err != nil {
+		panic(err)
+	}
+	if err := iniflags.RegisterCommandLineShorthand("t", "timeout"); err != nil {
+		panic(err)
+	}
+	iniflags.MarkSecret("dbPassword")
+}
+`
+	if string(code) != expected {
+		t.Fatalf("Unexpected code:\n%s\nExpected:\n%s", code, expected)
+	}
+}
+
+func TestParseDefinitionsError(t *testing.T) {
+	f := func(src string) {
+		t.Helper()
+		defs, err := parseDefinitions(strings.NewReader(src))
+		if err == nil {
+			_, err = generate(defs, "flags.yaml")
+		}
+		if err == nil {
+			t.Fatalf("Expecting error for %q", src)
+		}
+	}
+	f("unknown: foo\n")
+	f("flags:\n  - name: x\n    foo: bar\n")
+	f("flags:\n  - name: x\n    type: complex\n")
+	f("flags:\n  - name: x\n    type: int\n    default: foo\n")
+	f("flags:\n  - usage: foo\n")
+	f("flags:\n  name: x\n")
+	f("  - name: x\n")
+
+	// invalid variable names
+	f("flags:\n  - name: type\n")
+	f("flags:\n  - name: x\n    var: func\n")
+	f("flags:\n  - name: x\n    var: 1x\n")
+	f("flags:\n  - name: time\n")
+	f("flags:\n  - name: ...\n")
+	f("flags:\n  - name: db.host\n  - name: db-host\n")
+	f("flags:\n  - name: x\n  - name: y\n    var: x\n")
+
+	// invalid list
+	f("flags:\n  - name: x\n    type: stringSlice\n    default: '\"a, b'\n")
+}
+
+func TestGenerateCompiles(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("go command isn't available: %s", err)
+	}
+	src := `package: gentest
+flags:
+  - name: addr
+    default: ":8080"
+    shorthand: a
+  - name: verbose
+    type: bool
+    default: true
+  - name: db.max-conns
+    type: int
+    default: 10
+  - name: maxSize
+    type: int64
+    default: 1024
+  - name: workers
+    type: uint
+    default: 4
+  - name: maxRows
+    type: uint64
+    default: 100
+  - name: ratio
+    type: float64
+    default: 0.5
+  - name: timeout
+    type: duration
+    default: 1500ms
+    commandLineShorthand: t
+  - name: type
+    var: typeName
+  - name: paths
+    type: stringSlice
+    default: '"/a,b", /c'
+  - name: emptyPaths
+    type: stringSlice
+  - name: dbPassword
+    secret: true
+`
+	defs, err := parseDefinitions(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Cannot parse definitions: %s", err)
+	}
+	code, err := generate(defs, "flags.yaml")
+	if err != nil {
+		t.Fatalf("Cannot generate code: %s", err)
+	}
+	if !strings.Contains(string(code), `[]string{"/a,b", "/c"}`) {
+		t.Fatalf("Unexpected default for paths:\n%s", code)
+	}
+
+	// The package is created inside the module, so it may import iniflags.
+	// The leading underscore excludes it from ./... patterns.
+	dir, err := os.MkdirTemp(".", "_gentest")
+	if err != nil {
+		t.Fatalf("Cannot create package dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "flags_gen.go"), code, 0644); err != nil {
+		t.Fatalf("Cannot write generated code: %s", err)
+	}
+	cmd := exec.Command(goBin, "build", "./"+filepath.Base(dir))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Cannot compile generated code: %s\n%s\n%s", err, out, code)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	f := func(s string, expected []string) {
+		t.Helper()
+		items, err := parseList(s)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatalf("Unexpected items for %q: %q. Expected %q", s, items, expected)
+		}
+	}
+	f("", []string{})
+	f("/a, /b", []string{"/a", "/b"})
+	f("[a, b]", []string{"a", "b"})
+	f(`"a,b", c`, []string{"a,b", "c"})
+	f(`" a ", "b\"c"`, []string{" a ", `b"c`})
+}
+
+func TestDurationExpr(t *testing.T) {
+	f := func(d time.Duration, expected string) {
+		t.Helper()
+		if s := durationExpr(d); s != expected {
+			t.Fatalf("Unexpected expression for %s: %q. Expected %q", d, s, expected)
+		}
+	}
+	f(0, "0")
+	f(2*time.Hour, "2 * time.Hour")
+	f(90*time.Second, "90 * time.Second")
+	f(1500*time.Millisecond, "1500 * time.Millisecond")
+	f(3, "time.Duration(3)")
+}
+
+func TestVarName(t *testing.T) {
+	f := func(name, expected string) {
+		t.Helper()
+		if s := varName(name); s != expected {
+			t.Fatalf("Unexpected var name for %q: %q. Expected %q", name, s, expected)
+		}
+	}
+	f("addr", "addr")
+	f("db.max-conns", "dbMaxConns")
+	f("http_port", "httpPort")
+	f("2fa", "_2fa")
+}
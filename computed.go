@@ -0,0 +1,85 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// computedDefaults maps flag names to functions returning their defaults.
+var computedDefaults = make(map[string]func() string)
+
+// StringFunc defines a string flag with the given name and usage,
+// which default value is returned by defFn.
+//
+// defFn is called during Parse() instead of the flag definition,
+// so the default may depend on the environment, e.g. the current user's
+// home directory, or may be expensive to compute. defFn isn't called
+// if the flag is set via command line. Values from environment variables,
+// SetOverrides() and config files override the computed default as usual.
+//
+// The return value is the address of a string variable that stores
+// the value of the flag.
+func StringFunc(name string, defFn func() string, usage string) *string {
+	p := flag.String(name, "", usage)
+	registerComputedDefault(name, defFn)
+	return p
+}
+
+// IntFunc defines an int flag with the given name and usage,
+// which default value is returned by defFn.
+//
+// See StringFunc() for details.
+func IntFunc(name string, defFn func() int, usage string) *int {
+	p := flag.Int(name, 0, usage)
+	registerComputedDefault(name, func() string {
+		return strconv.Itoa(defFn())
+	})
+	return p
+}
+
+// DurationFunc defines a time.Duration flag with the given name and usage,
+// which default value is returned by defFn.
+//
+// See StringFunc() for details.
+func DurationFunc(name string, defFn func() time.Duration, usage string) *time.Duration {
+	p := flag.Duration(name, 0, usage)
+	registerComputedDefault(name, func() string {
+		return defFn().String()
+	})
+	return p
+}
+
+func registerComputedDefault(name string, defFn func() string) {
+	if parsed {
+		logger.Panicf("iniflags: computed default for flag [%s] must be defined before Parse()", name)
+	}
+	computedDefaults[name] = defFn
+}
+
+// applyComputedDefaults sets computed defaults for flags
+// not set via command line.
+//
+// The computed value becomes the flag's default, so it is treated
+// as the default in -dumpflags output.
+func applyComputedDefaults() error {
+	names := make([]string, 0, len(computedDefaults))
+	for name := range computedDefaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if f == nil || isSetOnCommandLine(name) {
+			continue
+		}
+		value := computedDefaults[name]()
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("iniflags: cannot set computed default [%s] for flag [%s]: %s", value, name, err)
+		}
+		f.DefValue = f.Value.String()
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package iniflags
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestComputedDefaults(t *testing.T) {
+	defer func() { computedDefaults = make(map[string]func() string) }()
+
+	parsed = false
+	calls := 0
+	dataDir := StringFunc("computedDataDir", func() string {
+		calls++
+		return "/home/user/.data"
+	}, "flag for TestComputedDefaults")
+	workers := IntFunc("computedWorkers", func() int { return 8 }, "flag for TestComputedDefaults")
+	timeout := DurationFunc("computedTimeout", func() time.Duration { return time.Minute }, "flag for TestComputedDefaults")
+	if calls != 0 {
+		t.Fatalf("Computed default mustn't be evaluated before Parse()")
+	}
+
+	if err := applyComputedDefaults(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if calls != 1 {
+		t.Fatalf("Unexpected number of computed default calls: %d. Expected 1", calls)
+	}
+	if *dataDir != "/home/user/.data" {
+		t.Fatalf("Unexpected computedDataDir=[%s]. Expected [/home/user/.data]", *dataDir)
+	}
+	if *workers != 8 {
+		t.Fatalf("Unexpected computedWorkers=%d. Expected 8", *workers)
+	}
+	if *timeout != time.Minute {
+		t.Fatalf("Unexpected computedTimeout=%s. Expected 1m0s", *timeout)
+	}
+	if f := flag.Lookup("computedDataDir"); f.DefValue != "/home/user/.data" {
+		t.Fatalf("Computed value must become the default; got [%s]", f.DefValue)
+	}
+
+	computedDefaults["computedWorkers"] = func() string { return "foobar" }
+	if err := applyComputedDefaults(); err == nil {
+		t.Fatalf("Expecting error for invalid computed default")
+	}
+}
@@ -0,0 +1,193 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var (
+	requiredTogetherGroups  [][]string
+	mutuallyExclusiveGroups [][]string
+	optionalFlags           = make(map[string]bool)
+	requireAllFlags         bool
+	conditionalRequirements []conditionalRequirement
+)
+
+type conditionalRequirement struct {
+	requiredFlag   string
+	conditionFlag  string
+	conditionValue string
+}
+
+// RequireTogether declares that the given flags must be specified together.
+//
+// If any flag from the group is set via command line, environment variable
+// or config file, then all the other flags from the group must be set too.
+// Otherwise Parse() fails with an error naming the missing flags.
+//
+// For example, RequireTogether("tlsCert", "tlsKey").
+func RequireTogether(names ...string) {
+	if parsed {
+		logger.Panicf("iniflags: RequireTogether() must be called before Parse()")
+	}
+	requiredTogetherGroups = append(requiredTogetherGroups, append([]string(nil), names...))
+}
+
+// MutuallyExclusive declares that at most one flag from the given group
+// may be set via command line, environment variable or config file.
+//
+// Otherwise Parse() fails with an error naming the conflicting flags.
+//
+// For example, MutuallyExclusive("configURL", "configFile").
+func MutuallyExclusive(names ...string) {
+	if parsed {
+		logger.Panicf("iniflags: MutuallyExclusive() must be called before Parse()")
+	}
+	mutuallyExclusiveGroups = append(mutuallyExclusiveGroups, append([]string(nil), names...))
+}
+
+// RequireIf declares that requiredFlag must be specified if conditionFlag
+// equals conditionValue.
+//
+// For example, RequireIf("tlsCert", "tls", "true") requires tlsCert to be set
+// via command line, environment variable or config file if tls is enabled.
+// Otherwise Parse() fails with an error naming both the required flag
+// and the condition. Values are compared the same way as flag values
+// are parsed, e.g. "1" matches true for bool flags.
+func RequireIf(requiredFlag, conditionFlag, conditionValue string) {
+	if parsed {
+		logger.Panicf("iniflags: RequireIf() must be called before Parse()")
+	}
+	conditionalRequirements = append(conditionalRequirements, conditionalRequirement{
+		requiredFlag:   requiredFlag,
+		conditionFlag:  conditionFlag,
+		conditionValue: conditionValue,
+	})
+}
+
+// AllowUnsetFlag marks the flag with the given name or shorthand as optional,
+// so it may be omitted from config files.
+//
+// The flag is exempted from the check enabled via SetRequireAllFlags().
+// Shorthands are resolved when the check is performed, so they may be
+// registered after the call.
+func AllowUnsetFlag(flagName string) {
+	if parsed {
+		logger.Panicf("iniflags: AllowUnsetFlag() must be called before Parse()")
+	}
+	optionalFlags[flagName] = true
+}
+
+// SetRequireAllFlags enables the strict mode, which requires all the flags
+// to be present in config files.
+//
+// Loading a config fails with an error naming the missing flags then.
+// Flags set via command line, environment variables or SetOverrides(),
+// flags excluded from dump, sensitive flags and flags marked via
+// AllowUnsetFlag() are exempted from the check.
+func SetRequireAllFlags(require bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetRequireAllFlags() must be called before Parse()")
+	}
+	requireAllFlags = require
+}
+
+// getFlagsMissingInConfig returns sorted names of flags, which must be present
+// in config args according to SetRequireAllFlags().
+func getFlagsMissingInConfig(args []FlagArg) []string {
+	present := make(map[string]bool, len(args))
+	for _, arg := range args {
+		name := arg.Key
+		if fullName, ok := flagShorthands[name]; ok {
+			name = fullName
+		}
+		present[name] = true
+	}
+	optional := make(map[string]bool, len(optionalFlags))
+	for name := range optionalFlags {
+		if fullName, ok := flagShorthands[name]; ok {
+			name = fullName
+		}
+		optional[name] = true
+	}
+	missingFlags := getMissingFlags()
+	var missing []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if present[f.Name] || !missingFlags[f.Name] || envFlags[f.Name] || flagsToExcludeFromDump[f.Name] || sensitiveFlags[f.Name] || optional[f.Name] {
+			return
+		}
+		missing = append(missing, f.Name)
+	})
+	return missing
+}
+
+// isFlagSet returns true if the flag with the given name is explicitly
+// set via command line, environment variable, overrides or config file.
+func isFlagSet(name string) bool {
+	if configFlags[name] || envFlags[name] || overrideFlags[name] {
+		return true
+	}
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// validateFlagConstraints verifies constraints registered
+// via RequireTogether(), MutuallyExclusive() and RequireIf().
+func validateFlagConstraints() error {
+	for _, group := range requiredTogetherGroups {
+		set, missing, err := splitFlagGroup("RequireTogether", group)
+		if err != nil {
+			return err
+		}
+		if len(set) > 0 && len(missing) > 0 {
+			return fmt.Errorf("iniflags: flags [%s] must be set together with [%s]",
+				strings.Join(missing, ", "), strings.Join(set, ", "))
+		}
+	}
+	for _, group := range mutuallyExclusiveGroups {
+		set, _, err := splitFlagGroup("MutuallyExclusive", group)
+		if err != nil {
+			return err
+		}
+		if len(set) > 1 {
+			return fmt.Errorf("iniflags: flags [%s] are mutually exclusive, so only one of them may be set",
+				strings.Join(set, ", "))
+		}
+	}
+	for _, cr := range conditionalRequirements {
+		if flag.Lookup(cr.requiredFlag) == nil {
+			return fmt.Errorf("iniflags: unknown flag [%s] passed to RequireIf()", cr.requiredFlag)
+		}
+		f := flag.Lookup(cr.conditionFlag)
+		if f == nil {
+			return fmt.Errorf("iniflags: unknown flag [%s] passed to RequireIf()", cr.conditionFlag)
+		}
+		if f.Value.String() == canonicalFlagValue(f, cr.conditionValue) && !isFlagSet(cr.requiredFlag) {
+			return fmt.Errorf("iniflags: flag [%s] must be set because [%s=%s]",
+				cr.requiredFlag, cr.conditionFlag, f.Value.String())
+		}
+	}
+	return nil
+}
+
+// splitFlagGroup splits the given group into flags which are set
+// and flags which are missing.
+func splitFlagGroup(funcName string, group []string) (set, missing []string, err error) {
+	for _, name := range group {
+		if flag.Lookup(name) == nil {
+			return nil, nil, fmt.Errorf("iniflags: unknown flag [%s] passed to %s(%s)", name, funcName, strings.Join(group, ", "))
+		}
+		if isFlagSet(name) {
+			set = append(set, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	return set, missing, nil
+}
@@ -0,0 +1,132 @@
+package iniflags
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestRequireTogether(t *testing.T) {
+	flag.String("tlsCert", "", "flag for TestRequireTogether")
+	flag.String("tlsKey", "", "flag for TestRequireTogether")
+	defer func() {
+		requiredTogetherGroups = nil
+		configFlags = make(map[string]bool)
+	}()
+
+	parsed = false
+	RequireTogether("tlsCert", "tlsKey")
+	if err := validateFlagConstraints(); err != nil {
+		t.Fatalf("Unexpected error when no flags are set: %s", err)
+	}
+	configFlags = map[string]bool{"tlsCert": true}
+	if err := validateFlagConstraints(); err == nil {
+		t.Fatalf("Setting only tlsCert must fail")
+	}
+	configFlags = map[string]bool{"tlsCert": true, "tlsKey": true}
+	if err := validateFlagConstraints(); err != nil {
+		t.Fatalf("Unexpected error when all flags are set: %s", err)
+	}
+}
+
+func TestMutuallyExclusive(t *testing.T) {
+	flag.String("configURL", "", "flag for TestMutuallyExclusive")
+	flag.String("configFile", "", "flag for TestMutuallyExclusive")
+	defer func() {
+		mutuallyExclusiveGroups = nil
+		configFlags = make(map[string]bool)
+	}()
+
+	parsed = false
+	MutuallyExclusive("configURL", "configFile")
+	configFlags = map[string]bool{"configURL": true}
+	if err := validateFlagConstraints(); err != nil {
+		t.Fatalf("Unexpected error when a single flag is set: %s", err)
+	}
+	configFlags = map[string]bool{"configURL": true, "configFile": true}
+	if err := validateFlagConstraints(); err == nil {
+		t.Fatalf("Setting both configURL and configFile must fail")
+	}
+}
+
+func TestRequireIf(t *testing.T) {
+	tls := flag.Bool("requireIfTLS", false, "flag for TestRequireIf")
+	flag.String("requireIfCert", "", "flag for TestRequireIf")
+	defer func() {
+		conditionalRequirements = nil
+		configFlags = make(map[string]bool)
+		*tls = false
+	}()
+
+	parsed = false
+	RequireIf("requireIfCert", "requireIfTLS", "1")
+	if err := validateFlagConstraints(); err != nil {
+		t.Fatalf("Unexpected error when the condition isn't met: %s", err)
+	}
+	*tls = true
+	err := validateFlagConstraints()
+	if err == nil {
+		t.Fatalf("Missing requireIfCert must fail when requireIfTLS=true")
+	}
+	expected := "iniflags: flag [requireIfCert] must be set because [requireIfTLS=true]"
+	if err.Error() != expected {
+		t.Fatalf("Unexpected error %q. Expected %q", err, expected)
+	}
+	configFlags = map[string]bool{"requireIfCert": true}
+	if err := validateFlagConstraints(); err != nil {
+		t.Fatalf("Unexpected error when the required flag is set: %s", err)
+	}
+
+	conditionalRequirements = nil
+	RequireIf("requireIfCert", "missingFlag", "true")
+	if err := validateFlagConstraints(); err == nil {
+		t.Fatalf("Expecting error for unknown condition flag")
+	}
+}
+
+func TestSetRequireAllFlags(t *testing.T) {
+	flag.String("allowUnsetFlagFull", "", "flag for TestSetRequireAllFlags")
+	defer func() {
+		requireAllFlags = false
+		delete(optionalFlags, "x")
+		delete(optionalFlags, "allowUnsetShort")
+		delete(flagShorthands, "allowUnsetShort")
+	}()
+	parsed = false
+	SetRequireAllFlags(true)
+
+	contains := func(names []string, name string) bool {
+		for _, s := range names {
+			if s == name {
+				return true
+			}
+		}
+		return false
+	}
+	missing := getFlagsMissingInConfig(nil)
+	if !contains(missing, "x") {
+		t.Fatalf("x must be reported as missing; got %v", missing)
+	}
+	if contains(missing, "config") {
+		t.Fatalf("Flags excluded from dump mustn't be reported as missing; got %v", missing)
+	}
+	missing = getFlagsMissingInConfig([]FlagArg{{Key: "x", Value: "foo"}})
+	if contains(missing, "x") {
+		t.Fatalf("x mustn't be reported as missing when present in config; got %v", missing)
+	}
+	AllowUnsetFlag("x")
+	missing = getFlagsMissingInConfig(nil)
+	if contains(missing, "x") {
+		t.Fatalf("Optional flag x mustn't be reported as missing; got %v", missing)
+	}
+
+	// shorthands are resolved
+	if !contains(missing, "allowUnsetFlagFull") {
+		t.Fatalf("allowUnsetFlagFull must be reported as missing; got %v", missing)
+	}
+	AllowUnsetFlag("allowUnsetShort")
+	flagShorthands["allowUnsetShort"] = "allowUnsetFlagFull"
+	missing = getFlagsMissingInConfig(nil)
+	if contains(missing, "allowUnsetFlagFull") {
+		t.Fatalf("Flag allowed via shorthand mustn't be reported as missing; got %v", missing)
+	}
+}
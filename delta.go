@@ -0,0 +1,42 @@
+package iniflags
+
+var deltaFetcher func(sinceGeneration int) ([]FlagArg, error)
+
+// SetDeltaFetcher sets the function for fetching config changes on reload.
+//
+// When set, config reloads triggered via SIGHUP, TriggerReload()
+// or -configUpdateInterval apply only the values returned by fetcher instead
+// of re-reading the full config. This saves resources for large remote
+// configs, which rarely change. fetcher is called with the current Generation
+// and must return values changed since then. The full config is re-read
+// if fetcher returns an error or the returned values cannot be applied.
+//
+// Must be called before Parse().
+func SetDeltaFetcher(fetcher func(sinceGeneration int) ([]FlagArg, error)) {
+	if parsed {
+		logger.Panicf("iniflags: SetDeltaFetcher() must be called before Parse()")
+	}
+	deltaFetcher = fetcher
+}
+
+// applyConfigDelta applies values returned by the fetcher set via
+// SetDeltaFetcher().
+//
+// It returns false if the full config must be re-read.
+func applyConfigDelta() (oldFlagValues map[string]string, ok bool) {
+	args, err := deltaFetcher(Generation)
+	if err != nil {
+		logger.Printf("iniflags: cannot fetch config delta; re-reading the full config: %s", err)
+		return nil, false
+	}
+	oldFlagValues, newDynamicValues, newConfigFlags, ok := applyFlagArgs(args)
+	if !ok {
+		logger.Printf("iniflags: cannot apply config delta; re-reading the full config")
+		return nil, false
+	}
+	mergeDynamicValues(newDynamicValues)
+	for flagName := range newConfigFlags {
+		configFlags[flagName] = true
+	}
+	return oldFlagValues, true
+}
@@ -0,0 +1,49 @@
+package iniflags
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestSetDeltaFetcher(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = full\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		deltaFetcher = nil
+	}()
+	*config = path
+
+	var fetchErr error
+	var sinceGenerations []int
+	parsed = false
+	SetDeltaFetcher(func(sinceGeneration int) ([]FlagArg, error) {
+		sinceGenerations = append(sinceGenerations, sinceGeneration)
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return []FlagArg{{Key: "x", Value: "delta"}}, nil
+	})
+	parsed = true
+
+	generation := Generation
+	TriggerReload()
+	if *x != "delta" {
+		t.Fatalf("Unexpected x=[%s]. Expected [delta]", *x)
+	}
+	if len(sinceGenerations) != 1 || sinceGenerations[0] != generation {
+		t.Fatalf("Unexpected generations passed to fetcher: %v. Expected [%d]", sinceGenerations, generation)
+	}
+
+	fetchErr = fmt.Errorf("delta isn't available")
+	TriggerReload()
+	if *x != "full" {
+		t.Fatalf("Full config must be re-read on fetcher error; got x=[%s]", *x)
+	}
+}
@@ -0,0 +1,115 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// FlagDrift contains the current flag value and the value
+// from the config file, which differ.
+type FlagDrift struct {
+	Current string
+	File    string
+}
+
+// DriftFromFile compares current flag values with values from the config
+// file at the given path and returns the flags with differing values.
+//
+// This detects flags changed at runtime, e.g. via SetFlagValueWithoutCallback()
+// or an aborted reload, so the app no longer matches its config file.
+// The config file is parsed and its values are resolved and normalized
+// according to the global iniflags settings, but they aren't applied and
+// referenced files aren't tracked for changes. Flags set via command line
+// or SetOverrides() aren't reported, since config files cannot change them.
+// Keys not matching registered flags are ignored. Values of flags marked
+// via MarkSecret() or read via secret value schemes such as keyring://
+// are redacted.
+func DriftFromFile(path string) (map[string]FlagDrift, error) {
+	p := newConfigParser()
+	p.allowMissing = false
+	args, err := p.parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileValues := make(map[string]string)
+	secretFlags := make(map[string]bool)
+	for _, arg := range args {
+		name := arg.Key
+		if fullName, ok := flagShorthands[name]; ok {
+			name = fullName
+		}
+		f := flag.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if secretValueSchemes[valueScheme(trimFlagValue(f, arg.Value))] {
+			secretFlags[name] = true
+		}
+		value, err := resolveFlagValueExt(f, arg.Value, arg.FilePath, false)
+		if err != nil {
+			return nil, fmt.Errorf("iniflags: cannot resolve value for flag [%s] at line [%d] of file [%s]: %s", name, arg.LineNum, arg.FilePath, redactError(name, err, arg.Value))
+		}
+		fileValues[name] = value
+	}
+
+	missingFlags := getMissingFlags()
+	drift := make(map[string]FlagDrift)
+	for name, value := range fileValues {
+		if !missingFlags[name] {
+			continue
+		}
+		f := flag.Lookup(name)
+		current := f.Value.String()
+		if current == canonicalFlagValue(f, value) {
+			continue
+		}
+		if secretFlags[name] {
+			drift[name] = FlagDrift{
+				Current: redactedValue,
+				File:    redactedValue,
+			}
+			continue
+		}
+		drift[name] = FlagDrift{
+			Current: redactValue(name, current),
+			File:    redactValue(name, value),
+		}
+	}
+	return drift, nil
+}
+
+// canonicalFlagValue returns the value in the form returned by String()
+// of the flag f, so equal values written differently aren't reported
+// as drift.
+func canonicalFlagValue(f *flag.Flag, value string) string {
+	switch flagKind(f) {
+	case KindBool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return strconv.FormatBool(b)
+		}
+	case KindInt:
+		if n, err := strconv.ParseInt(value, 0, 64); err == nil {
+			return strconv.FormatInt(n, 10)
+		}
+	case KindUint:
+		if n, err := strconv.ParseUint(value, 0, 64); err == nil {
+			return strconv.FormatUint(n, 10)
+		}
+	case KindFloat:
+		if x, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(x, 'g', -1, 64)
+		}
+	case KindDuration:
+		if d, err := time.ParseDuration(value); err == nil {
+			return d.String()
+		}
+	case KindList:
+		if items, err := parseList(value); err == nil {
+			return formatList(items)
+		}
+	}
+	return value
+}
@@ -0,0 +1,98 @@
+package iniflags
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDriftFromFile(t *testing.T) {
+	driftName := flag.String("driftName", "", "flag for TestDriftFromFile")
+	driftTimeout := flag.Duration("driftTimeout", 0, "flag for TestDriftFromFile")
+	flag.Bool("driftEnabled", false, "flag for TestDriftFromFile")
+
+	path := t.TempDir() + "/drift.ini"
+	content := "driftName = foo\ndriftTimeout = 60s\ndriftEnabled = 1\nunknownDriftKey = bar\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldAllowMissing := *allowMissingConfig
+	defer func() { *allowMissingConfig = oldAllowMissing }()
+	*allowMissingConfig = false
+
+	*driftName = "foo"
+	*driftTimeout = time.Minute
+	if err := flag.Lookup("driftEnabled").Value.Set("true"); err != nil {
+		t.Fatalf("Cannot set flag: %s", err)
+	}
+	drift, err := DriftFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(drift) != 0 {
+		t.Fatalf("Unexpected drift for matching values: %v", drift)
+	}
+
+	*driftName = "changed"
+	drift, err = DriftFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(drift) != 1 || drift["driftName"] != (FlagDrift{Current: "changed", File: "foo"}) {
+		t.Fatalf("Unexpected drift: %v. Expected driftName drift", drift)
+	}
+
+	if _, err := DriftFromFile(path + ".missing"); err == nil {
+		t.Fatalf("Expecting error for missing config file")
+	}
+}
+
+func TestDriftFromFileNormalized(t *testing.T) {
+	driftRatio := flag.Float64("driftRatio", 0, "flag for TestDriftFromFileNormalized")
+	flag.Int("driftWorkers", 0, "flag for TestDriftFromFileNormalized")
+	driftToken := flag.String("driftToken", "", "flag for TestDriftFromFileNormalized")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/token", []byte("secret\n"), 0644); err != nil {
+		t.Fatalf("Cannot write referenced file: %s", err)
+	}
+	path := dir + "/drift.ini"
+	content := "driftRatio = 0,5\ndriftWorkers = -3\ndriftToken = @./token\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	defer func() {
+		normalizeDecimalComma = false
+		allowFileRefs = false
+		delete(typeNormalizers, KindInt)
+	}()
+	parsed = false
+	SetNormalizeDecimalComma(true)
+	SetAllowFileRefs(true)
+	SetTypeNormalizer(KindInt, func(v string) (string, error) {
+		if strings.HasPrefix(v, "-") {
+			return "0", nil
+		}
+		return v, nil
+	})
+
+	*driftRatio = 0.5
+	*driftToken = "secret"
+	drift, err := DriftFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(drift) != 0 {
+		t.Fatalf("Unexpected drift for normalized values: %v", drift)
+	}
+
+	// DriftFromFile mustn't track files referenced by the config.
+	fileRefsMu.Lock()
+	_, tracked := fileRefs["driftToken"]
+	fileRefsMu.Unlock()
+	if tracked {
+		t.Fatalf("DriftFromFile mustn't track referenced files")
+	}
+}
@@ -0,0 +1,85 @@
+package iniflags
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// FlagInfo describes a flag for templates executed by DumpWith().
+type FlagInfo struct {
+	// Name is the flag name.
+	Name string
+
+	// Value is the current flag value in the form used by -dumpflags.
+	Value string
+
+	// Default is the default flag value.
+	Default string
+
+	// Usage is the flag description.
+	Usage string
+
+	// Excluded is set for flags excluded from -dumpflags output
+	// via ExcludeFlagFromDump() or SetDumpFilter() and for flags
+	// holding sensitive values.
+	Excluded bool
+}
+
+// EnvName returns the name of the environment variable for the flag
+// according to SetFromProcessEnv().
+//
+// For example, the name for -log-level flag is APP_LOG_LEVEL
+// for SetFromProcessEnv("APP").
+func (fi FlagInfo) EnvName() string {
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(fi.Name))
+	if processEnvPrefix != "" {
+		name = processEnvPrefix + "_" + name
+	}
+	return name
+}
+
+// DotEnvTemplate is a template for DumpWith(), which writes flags
+// in .env format suitable for Docker Compose env_file.
+const DotEnvTemplate = `{{range .}}{{if not .Excluded}}{{.EnvName}}={{printf "%q" .Value}}
+{{end}}{{end}}`
+
+// ConfigMapTemplate is a template for DumpWith(), which writes flags
+// as Kubernetes ConfigMap. Its keys are environment variable names,
+// so the ConfigMap may be passed to the app via envFrom.
+const ConfigMapTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: iniflags-config
+data:
+{{- range .}}{{if not .Excluded}}
+  {{.EnvName}}: {{printf "%q" .Value}}
+{{- end}}{{end}}
+`
+
+// DumpWith executes tmpl against []FlagInfo describing all the flags
+// defined in the application sorted by name and writes the result to w.
+//
+// This allows writing flags in arbitrary formats. See DotEnvTemplate
+// and ConfigMapTemplate for examples:
+//
+//	tmpl := template.Must(template.New("env").Parse(iniflags.DotEnvTemplate))
+//	iniflags.DumpWith(os.Stdout, tmpl)
+func DumpWith(w io.Writer, tmpl *template.Template) error {
+	var infos []FlagInfo
+	flag.VisitAll(func(f *flag.Flag) {
+		excluded := flagsToExcludeFromDump[f.Name] || isSecretFlag(f.Name)
+		if dumpFilter != nil && !dumpFilter(f.Name) {
+			excluded = true
+		}
+		infos = append(infos, FlagInfo{
+			Name:     f.Name,
+			Value:    dumpValue(f.Value),
+			Default:  f.DefValue,
+			Usage:    f.Usage,
+			Excluded: excluded,
+		})
+	})
+	return tmpl.Execute(w, infos)
+}
@@ -0,0 +1,45 @@
+package iniflags
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"text/template"
+)
+
+func TestDumpWith(t *testing.T) {
+	flag.String("dump-with-addr", ":8080", "flag for TestDumpWith")
+	defer func() { processEnvPrefix = "" }()
+	processEnvPrefix = "APP"
+	isolateDump(t, "dump-with-addr", "config")
+
+	var buf bytes.Buffer
+	tmpl := template.Must(template.New("env").Parse(DotEnvTemplate))
+	if err := DumpWith(&buf, tmpl); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	// The excluded -config flag mustn't be written.
+	expected := "APP_DUMP_WITH_ADDR=\":8080\"\n"
+	if buf.String() != expected {
+		t.Fatalf("Unexpected .env output %q. Expected %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	tmpl = template.Must(template.New("configmap").Parse(ConfigMapTemplate))
+	if err := DumpWith(&buf, tmpl); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	expected = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: iniflags-config\ndata:\n  APP_DUMP_WITH_ADDR: \":8080\"\n"
+	if buf.String() != expected {
+		t.Fatalf("Unexpected ConfigMap output %q. Expected %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	tmpl = template.Must(template.New("custom").Parse(`{{range .}}{{if eq .Name "dump-with-addr"}}{{.Name}}|{{.Default}}|{{.Usage}}{{end}}{{end}}`))
+	if err := DumpWith(&buf, tmpl); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	if buf.String() != "dump-with-addr|:8080|flag for TestDumpWith" {
+		t.Fatalf("Unexpected custom template output: %q", buf.String())
+	}
+}
@@ -0,0 +1,219 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dynamicValueTypes contains type names allowed in "key:type = value"
+// annotations for dynamic values.
+var dynamicValueTypes = map[string]bool{
+	"string":   true,
+	"bool":     true,
+	"int":      true,
+	"int64":    true,
+	"uint":     true,
+	"uint64":   true,
+	"float64":  true,
+	"duration": true,
+}
+
+type dynamicValue struct {
+	value string
+	typ   string
+}
+
+var (
+	dynamicValues   = make(map[string]dynamicValue)
+	dynamicValuesMu sync.RWMutex
+)
+
+// splitKeyType splits "key:type" config key into the key and the type.
+//
+// Keys without a known type suffix are returned as is.
+func splitKeyType(key string) (string, string) {
+	n := strings.LastIndexByte(key, ':')
+	if n < 0 || !dynamicValueTypes[key[n+1:]] {
+		return key, ""
+	}
+	return strings.TrimSpace(key[:n]), key[n+1:]
+}
+
+func setDynamicValues(values map[string]dynamicValue) {
+	dynamicValuesMu.Lock()
+	dynamicValues = values
+	dynamicValuesMu.Unlock()
+}
+
+// mergeDynamicValues adds the given values to the current dynamic values.
+func mergeDynamicValues(values map[string]dynamicValue) {
+	dynamicValuesMu.Lock()
+	for k, v := range values {
+		dynamicValues[k] = v
+	}
+	dynamicValuesMu.Unlock()
+}
+
+// checkDynamicValueType verifies whether the value can be parsed
+// as the given type.
+func checkDynamicValueType(value, typ string) error {
+	var err error
+	switch typ {
+	case "", "string":
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "int", "int64":
+		_, err = strconv.ParseInt(value, 0, 64)
+	case "uint", "uint64":
+		_, err = strconv.ParseUint(value, 0, 64)
+	case "float64":
+		_, err = strconv.ParseFloat(value, 64)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	default:
+		err = fmt.Errorf("unknown type [%s]", typ)
+	}
+	return err
+}
+
+// DynamicValue returns the value for the given key read from config files,
+// which doesn't correspond to any registered flag.
+//
+// Dynamic values are captured only if -allowUnknownFlags is set.
+// Their intended type may be annotated in config files with the
+// "key:type = value" syntax, e.g. "plugin.timeout:duration = 5s".
+// Supported types are string, bool, int, int64, uint, uint64, float64
+// and duration. Values are verified against the annotated type when
+// config files are parsed. Typed values may be obtained via getters
+// such as GetInt() for compatible types, e.g. int64 values via GetInt(),
+// and via GetString() for any type.
+func DynamicValue(key string) (value string, ok bool) {
+	dynamicValuesMu.RLock()
+	v, ok := dynamicValues[key]
+	dynamicValuesMu.RUnlock()
+	return v.value, ok
+}
+
+// isCompatibleDynamicType returns true if the dynamic value annotated
+// with typ may be obtained as the requested type.
+//
+// Values of any type may be obtained as string, integer values may be
+// obtained as float64, while int and uint values may be obtained as int64
+// and uint64 respectively and vice versa. Unsigned values may be obtained
+// as signed values.
+func isCompatibleDynamicType(typ, requested string) bool {
+	if typ == "" || typ == requested || requested == "string" {
+		return true
+	}
+	isSigned := typ == "int" || typ == "int64"
+	isUnsigned := typ == "uint" || typ == "uint64"
+	switch requested {
+	case "int", "int64", "float64":
+		return isSigned || isUnsigned
+	case "uint", "uint64":
+		return isUnsigned
+	default:
+		return false
+	}
+}
+
+// lookupValue returns the value for the given registered flag
+// or dynamic value, and verifies the dynamic value is compatible
+// with the requested type.
+func lookupValue(name, typ string) (string, error) {
+	if fullName, ok := flagShorthands[name]; ok {
+		name = fullName
+	}
+	if f := flag.Lookup(name); f != nil {
+		recordFlagRead(name)
+		return f.Value.String(), nil
+	}
+	dynamicValuesMu.RLock()
+	v, ok := dynamicValues[name]
+	dynamicValuesMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("iniflags: unknown flag [%s]", name)
+	}
+	recordFlagRead(name)
+	if !isCompatibleDynamicType(v.typ, typ) {
+		return "", fmt.Errorf("iniflags: cannot obtain value [%s] of type [%s] as [%s]", name, v.typ, typ)
+	}
+	return v.value, nil
+}
+
+// GetString returns the value of the given flag or dynamic value as string.
+//
+// Dynamic values of any type may be obtained as string.
+func GetString(name string) (string, error) {
+	return lookupValue(name, "string")
+}
+
+// GetBool returns the value of the given flag or dynamic value as bool.
+func GetBool(name string) (bool, error) {
+	s, err := lookupValue(name, "bool")
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(s)
+}
+
+// GetInt returns the value of the given flag or dynamic value as int.
+func GetInt(name string) (int, error) {
+	s, err := lookupValue(name, "int")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	return int(n), err
+}
+
+// GetInt64 returns the value of the given flag or dynamic value as int64.
+func GetInt64(name string) (int64, error) {
+	s, err := lookupValue(name, "int64")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 0, 64)
+}
+
+// GetUint returns the value of the given flag or dynamic value as uint.
+func GetUint(name string) (uint, error) {
+	s, err := lookupValue(name, "uint")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(s, 0, strconv.IntSize)
+	return uint(n), err
+}
+
+// GetUint64 returns the value of the given flag or dynamic value as uint64.
+func GetUint64(name string) (uint64, error) {
+	s, err := lookupValue(name, "uint64")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 0, 64)
+}
+
+// GetFloat64 returns the value of the given flag or dynamic value as float64.
+func GetFloat64(name string) (float64, error) {
+	s, err := lookupValue(name, "float64")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// GetDuration returns the value of the given flag or dynamic value
+// as time.Duration.
+func GetDuration(name string) (time.Duration, error) {
+	s, err := lookupValue(name, "duration")
+	if err != nil {
+		return 0, err
+	}
+	return time.ParseDuration(s)
+}
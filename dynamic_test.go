@@ -0,0 +1,89 @@
+package iniflags
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSplitKeyType(t *testing.T) {
+	key, typ := splitKeyType("plugin.timeout:duration")
+	if key != "plugin.timeout" || typ != "duration" {
+		t.Fatalf("Unexpected key=%q, type=%q", key, typ)
+	}
+	key, typ = splitKeyType("host:port")
+	if key != "host:port" || typ != "" {
+		t.Fatalf("Unexpected key=%q, type=%q", key, typ)
+	}
+}
+
+func TestDynamicValues(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	content := "plugin.timeout:duration = 5s\nplugin.name = foo\nplugin.retries:int64 = 5\nplugin.workers:uint = 3\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldAllowUnknownFlags := *allowUnknownFlags
+	defer func() {
+		*config = oldConfig
+		*allowUnknownFlags = oldAllowUnknownFlags
+		setDynamicValues(make(map[string]dynamicValue))
+	}()
+	*config = path
+	*allowUnknownFlags = true
+
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse config with dynamic values")
+	}
+	d, err := GetDuration("plugin.timeout")
+	if err != nil || d != 5*time.Second {
+		t.Fatalf("Unexpected duration %s, err=%v. Expected 5s", d, err)
+	}
+	if _, err = GetInt("plugin.timeout"); err == nil {
+		t.Fatalf("Obtaining duration value as int must fail")
+	}
+	if s, err := GetString("plugin.timeout"); err != nil || s != "5s" {
+		t.Fatalf("Unexpected string value %q, err=%v. Expected %q", s, err, "5s")
+	}
+	if n, err := GetInt("plugin.retries"); err != nil || n != 5 {
+		t.Fatalf("Unexpected int value %d, err=%v. Expected 5", n, err)
+	}
+	if n, err := GetInt64("plugin.retries"); err != nil || n != 5 {
+		t.Fatalf("Unexpected int64 value %d, err=%v. Expected 5", n, err)
+	}
+	if f, err := GetFloat64("plugin.retries"); err != nil || f != 5 {
+		t.Fatalf("Unexpected float64 value %v, err=%v. Expected 5", f, err)
+	}
+	if _, err := GetUint("plugin.retries"); err == nil {
+		t.Fatalf("Obtaining int64 value as uint must fail")
+	}
+	if n, err := GetUint("plugin.workers"); err != nil || n != 3 {
+		t.Fatalf("Unexpected uint value %d, err=%v. Expected 3", n, err)
+	}
+	if n, err := GetUint64("plugin.workers"); err != nil || n != 3 {
+		t.Fatalf("Unexpected uint64 value %d, err=%v. Expected 3", n, err)
+	}
+	if n, err := GetInt("plugin.workers"); err != nil || n != 3 {
+		t.Fatalf("Unexpected int value %d for uint, err=%v. Expected 3", n, err)
+	}
+	if v, ok := DynamicValue("plugin.name"); !ok || v != "foo" {
+		t.Fatalf("Unexpected dynamic value %q. Expected %q", v, "foo")
+	}
+	if v, err := GetString("x"); err != nil || v != *x {
+		t.Fatalf("Unexpected value %q for registered flag, err=%v. Expected %q", v, err, *x)
+	}
+
+	if err := os.WriteFile(path, []byte("plugin.timeout:duration = soon\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	if _, ok := parseConfigFlags(); ok {
+		t.Fatalf("Dynamic value not matching its type must fail parsing")
+	}
+	if err := os.WriteFile(path, []byte("x:string = foo\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	if _, ok := parseConfigFlags(); ok {
+		t.Fatalf("Type annotation for registered flag must fail parsing")
+	}
+}
@@ -0,0 +1,167 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	processEnvPrefix string
+	envFlags         = make(map[string]bool) // Flags set via environment variables
+)
+
+// SetFromProcessEnv enables reading flag values from environment variables
+// with names starting with the given prefix followed by '_'.
+//
+// The prefix is stripped from the variable name, then the name is lowercased
+// and '_' chars are replaced by '-'. For example, APP_LOG_LEVEL=debug sets
+// -log-level flag to "debug" for SetFromProcessEnv("APP"). Flag names
+// are matched case-insensitively with separators ignored, so both
+// APP_LOGLEVEL and APP_LOG_LEVEL set -logLevel flag if there is
+// no -log-level flag.
+// Variables not matching any flag are ignored. Parse() fails if a variable
+// matches multiple flags or multiple variables match the same flag.
+//
+// Values from environment variables are applied before config files,
+// so values from config files, SetOverrides() and command line override
+// values from environment variables. See EnableEnvVars() for the full
+// precedence order.
+func SetFromProcessEnv(prefix string) {
+	if parsed {
+		logger.Panicf("iniflags: SetFromProcessEnv() must be called before Parse()")
+	}
+	processEnvPrefix = prefix
+}
+
+// EnableEnvVars enables reading flag values from environment variables
+// named PREFIX_FLAG_NAME, e.g. MYAPP_LOG_LEVEL=debug sets -logLevel flag
+// to "debug" for EnableEnvVars("MYAPP").
+//
+// Variable names are matched against flag names case-insensitively
+// with '_' treated as a word separator, so MYAPP_LOGLEVEL sets -logLevel
+// as well. The precedence of flag values is the following:
+//
+//   - command line;
+//   - SetOverrides();
+//   - config files;
+//   - environment variables;
+//   - flag defaults.
+//
+// So environment variables provide fallback values for flags missing
+// in config files. Config reloads may override them as well.
+//
+// Parse() fails with an error naming the flag and the variable if the value
+// cannot be set or if the variable name is ambiguous. EnableEnvVars is
+// equivalent to SetFromProcessEnv(). Must be called before Parse().
+func EnableEnvVars(prefix string) {
+	SetFromProcessEnv(prefix)
+}
+
+// parseProcessEnvFlags applies values from environment variables
+// to flags not set via command line.
+//
+// It must be called before applying config files and overrides,
+// so they take precedence over environment variables.
+func parseProcessEnvFlags() error {
+	if processEnvPrefix == "" {
+		return nil
+	}
+	missingFlags := getMissingFlags()
+	prefix := processEnvPrefix + "_"
+	var environ []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			environ = append(environ, kv)
+		}
+	}
+	// Sort variables, so errors for ambiguous variables are stable.
+	sort.Strings(environ)
+	flagVars := make(map[string]string)
+	for _, kv := range environ {
+		n := strings.IndexByte(kv, '=')
+		if n < 0 {
+			continue
+		}
+		name := strings.ReplaceAll(strings.ToLower(kv[len(prefix):n]), "_", "-")
+		f, err := lookupFlagFold(name)
+		if err != nil {
+			return fmt.Errorf("iniflags: cannot apply environment variable [%s]: %s", kv[:n], err)
+		}
+		if f == nil {
+			continue
+		}
+		if varName, ok := flagVars[f.Name]; ok {
+			return fmt.Errorf("iniflags: flag [%s] is set by multiple environment variables: [%s] and [%s]", f.Name, varName, kv[:n])
+		}
+		flagVars[f.Name] = kv[:n]
+		if !missingFlags[f.Name] {
+			continue
+		}
+		value, err := resolveFlagValue(f, kv[n+1:], "")
+		if err == nil {
+			err = setFlagValue(f, value)
+		}
+		if err != nil {
+			return fmt.Errorf("iniflags: error when parsing flag [%s] value [%s] from environment variable [%s]: [%s]", f.Name, redactValue(f.Name, kv[n+1:]), kv[:n], redactError(f.Name, err, kv[n+1:], value))
+		}
+		envFlags[f.Name] = true
+		if traceHook != nil {
+			traceHook("env", f.Name, redactValue(f.Name, f.Value.String()))
+		}
+	}
+	return nil
+}
+
+// lookupFlagFold returns the flag with the given name, shorthand
+// or the flag with the name matching the given name case-insensitively.
+//
+// Names are also matched with '-', '_' and '.' separators ignored,
+// so "log-level" matches -logLevel flag. An error is returned if the name
+// matches multiple flags. Nil flag is returned if no flags match the name.
+func lookupFlagFold(name string) (*flag.Flag, error) {
+	if f := flag.Lookup(name); f != nil {
+		return f, nil
+	}
+	if fullName, ok := flagShorthands[name]; ok {
+		return flag.Lookup(fullName), nil
+	}
+	f, err := lookupSingleFlag(name, func(f *flag.Flag) bool {
+		return strings.EqualFold(f.Name, name)
+	})
+	if f != nil || err != nil {
+		return f, err
+	}
+	stripped := stripNameSeparators(name)
+	return lookupSingleFlag(name, func(f *flag.Flag) bool {
+		return strings.EqualFold(stripNameSeparators(f.Name), stripped)
+	})
+}
+
+// lookupSingleFlag returns the flag matching the given name according
+// to match. An error is returned if multiple flags match the name.
+func lookupSingleFlag(name string, match func(f *flag.Flag) bool) (*flag.Flag, error) {
+	var found []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if match(f) {
+			found = append(found, f.Name)
+		}
+	})
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return flag.Lookup(found[0]), nil
+	default:
+		return nil, fmt.Errorf("name [%s] matches multiple flags %v", name, found)
+	}
+}
+
+// nameSeparatorsRemover removes separators from flag names.
+var nameSeparatorsRemover = strings.NewReplacer("-", "", "_", "", ".", "")
+
+func stripNameSeparators(name string) string {
+	return nameSeparatorsRemover.Replace(name)
+}
@@ -0,0 +1,156 @@
+package iniflags
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetFromProcessEnv(t *testing.T) {
+	logLevel := flag.String("log-level", "info", "flag for TestSetFromProcessEnv")
+	workers := flag.Int("envWorkers", 1, "flag for TestSetFromProcessEnv")
+	defer func() {
+		processEnvPrefix = ""
+		envFlags = make(map[string]bool)
+	}()
+	t.Setenv("APP_LOG_LEVEL", "debug")
+	t.Setenv("APP_ENVWORKERS", "8")
+	t.Setenv("APP_UNKNOWN", "foo")
+
+	parsed = false
+	SetFromProcessEnv("APP")
+	if err := parseProcessEnvFlags(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *logLevel != "debug" {
+		t.Fatalf("Unexpected log-level=[%s]. Expected [debug]", *logLevel)
+	}
+	if *workers != 8 {
+		t.Fatalf("Unexpected envWorkers=%d. Expected 8", *workers)
+	}
+	if !getMissingFlags()["log-level"] {
+		t.Fatalf("Flags set via environment variables must be overridable by config")
+	}
+
+	t.Setenv("APP_ENVWORKERS", "foo")
+	envFlags = make(map[string]bool)
+	if err := parseProcessEnvFlags(); err == nil {
+		t.Fatalf("Expecting error for invalid value")
+	}
+}
+
+func TestEnableEnvVars(t *testing.T) {
+	logLevel := flag.String("envLogLevel", "info", "flag for TestEnableEnvVars")
+	maxConns := flag.Int("env.max-conns", 1, "flag for TestEnableEnvVars")
+	defer func() {
+		processEnvPrefix = ""
+		envFlags = make(map[string]bool)
+	}()
+	t.Setenv("MYAPP_ENV_LOG_LEVEL", "debug")
+	t.Setenv("MYAPP_ENV_MAX_CONNS", "16")
+
+	parsed = false
+	EnableEnvVars("MYAPP")
+	if err := parseProcessEnvFlags(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *logLevel != "debug" {
+		t.Fatalf("Unexpected envLogLevel=[%s]. Expected [debug]", *logLevel)
+	}
+	if *maxConns != 16 {
+		t.Fatalf("Unexpected env.max-conns=%d. Expected 16", *maxConns)
+	}
+	if !envFlags["envLogLevel"] || !envFlags["env.max-conns"] {
+		t.Fatalf("Flags set via environment variables must be recorded: %v", envFlags)
+	}
+
+	t.Setenv("MYAPP_ENV_MAX_CONNS", "many")
+	envFlags = make(map[string]bool)
+	err := parseProcessEnvFlags()
+	if err == nil || !strings.Contains(err.Error(), "MYAPP_ENV_MAX_CONNS") {
+		t.Fatalf("Unexpected error: %v. Expected error naming the variable", err)
+	}
+}
+
+func TestEnvVarsAmbiguous(t *testing.T) {
+	flag.String("envAmbiguousLevel", "", "flag for TestEnvVarsAmbiguous")
+	flag.Int("envSlot.size", 0, "flag for TestEnvVarsAmbiguous")
+	flag.Int("envSlotSize", 0, "flag for TestEnvVarsAmbiguous")
+	defer func() {
+		processEnvPrefix = ""
+		envFlags = make(map[string]bool)
+	}()
+
+	f := func(prefix, expected string) {
+		t.Helper()
+		envFlags = make(map[string]bool)
+		parsed = false
+		EnableEnvVars(prefix)
+		err := parseProcessEnvFlags()
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Unexpected error: %v. Expected error containing %q", err, expected)
+		}
+	}
+
+	// multiple variables match the same flag
+	t.Setenv("AMBIG1_ENV_AMBIGUOUS_LEVEL", "debug")
+	t.Setenv("AMBIG1_ENVAMBIGUOUSLEVEL", "info")
+	f("AMBIG1", "[AMBIG1_ENVAMBIGUOUSLEVEL] and [AMBIG1_ENV_AMBIGUOUS_LEVEL]")
+
+	// a variable matches multiple flags
+	t.Setenv("AMBIG2_ENV_SLOT_SIZE", "3")
+	f("AMBIG2", "matches multiple flags [envSlot.size envSlotSize]")
+}
+
+func TestEnvVarsPrecedence(t *testing.T) {
+	fromBoth := flag.String("envPrecedenceBoth", "default", "flag for TestEnvVarsPrecedence")
+	fromEnv := flag.String("envPrecedenceEnv", "default", "flag for TestEnvVarsPrecedence")
+	fromCLI := flag.String("envPrecedenceCLI", "default", "flag for TestEnvVarsPrecedence")
+
+	path := t.TempDir() + "/config.ini"
+	content := "envPrecedenceBoth = config\nenvPrecedenceCLI = config\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldAllowMissingConfig := *allowMissingConfig
+	defer func() {
+		*config = oldConfig
+		*allowMissingConfig = oldAllowMissingConfig
+		processEnvPrefix = ""
+		envFlags = make(map[string]bool)
+		parsed = true
+	}()
+	*config = path
+	*allowMissingConfig = false
+	t.Setenv("PREC_ENV_PRECEDENCE_BOTH", "env")
+	t.Setenv("PREC_ENV_PRECEDENCE_ENV", "env")
+	t.Setenv("PREC_ENV_PRECEDENCE_CLI", "env")
+
+	parsed = false
+	EnableEnvVars("PREC")
+	if err := ParseWithContext(context.Background(), []string{"app", "-envPrecedenceCLI=cli"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *fromBoth != "config" {
+		t.Fatalf("Unexpected envPrecedenceBoth=[%s]. Config value must override environment variable", *fromBoth)
+	}
+	if *fromEnv != "env" {
+		t.Fatalf("Unexpected envPrecedenceEnv=[%s]. Expected value from environment variable", *fromEnv)
+	}
+	if *fromCLI != "cli" {
+		t.Fatalf("Unexpected envPrecedenceCLI=[%s]. Command line must override config and environment variable", *fromCLI)
+	}
+
+	// config reload overrides values from environment variables as well
+	content = "envPrecedenceBoth = reloaded\nenvPrecedenceEnv = reloaded\nenvPrecedenceCLI = reloaded\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	TriggerReload()
+	if *fromBoth != "reloaded" || *fromEnv != "reloaded" || *fromCLI != "cli" {
+		t.Fatalf("Unexpected values after reload: envPrecedenceBoth=[%s], envPrecedenceEnv=[%s], envPrecedenceCLI=[%s]", *fromBoth, *fromEnv, *fromCLI)
+	}
+}
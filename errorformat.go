@@ -0,0 +1,78 @@
+package iniflags
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrorOutputFormat is the format for config errors reported by Parse().
+type ErrorOutputFormat int
+
+const (
+	// FormatText logs config errors as text messages via the logger
+	// set by SetLogger().
+	FormatText ErrorOutputFormat = iota
+
+	// FormatJSON writes config errors to stderr as JSON lines
+	// with file, line, key and message fields.
+	FormatJSON
+)
+
+var (
+	errorOutputFormat           = FormatText
+	errorOutput       io.Writer = os.Stderr
+	errorOutputMu     sync.Mutex
+)
+
+// SetErrorOutputFormat sets the format for config errors.
+//
+// FormatJSON allows CI pipelines to parse errors found in config files,
+// e.g. for annotating pull requests with error locations. The file, line
+// and key fields are empty for errors, which cannot be attributed to
+// a particular line, such as violated RequireTogether() constraints.
+// Must be called before Parse().
+func SetErrorOutputFormat(format ErrorOutputFormat) {
+	if parsed {
+		logger.Panicf("iniflags: SetErrorOutputFormat() must be called before Parse()")
+	}
+	errorOutputFormat = format
+}
+
+type jsonConfigError struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+// reportConfigError reports the error found in config.
+//
+// The first error reported during Parse() or config reload is captured,
+// so it is returned from ParseE() or passed to handlers registered
+// via OnConfigReloadFailure().
+//
+// text is logged if the error output format is FormatText.
+func reportConfigError(pe ParseError, text string) {
+	if capturingErrors && firstConfigError == nil {
+		firstConfigError = &pe
+	}
+	if errorOutputFormat != FormatJSON {
+		logger.Printf("%s", text)
+		return
+	}
+	data, err := json.Marshal(jsonConfigError{
+		File:    pe.FilePath,
+		Line:    pe.LineNum,
+		Key:     pe.Key,
+		Message: pe.Err.Error(),
+	})
+	if err != nil {
+		logger.Printf("iniflags: cannot marshal config error to JSON: %s; error: %s", err, text)
+		return
+	}
+	errorOutputMu.Lock()
+	errorOutput.Write(append(data, '\n'))
+	errorOutputMu.Unlock()
+}
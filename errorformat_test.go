@@ -0,0 +1,68 @@
+package iniflags
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetErrorOutputFormat(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = valid\nerrorFormatMissing = 1\nx:int = 2\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	var bb bytes.Buffer
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		errorOutputFormat = FormatText
+		errorOutput = os.Stderr
+	}()
+	*config = path
+	*allowUnknownFlags = false
+	errorOutput = &bb
+
+	parsed = false
+	SetErrorOutputFormat(FormatJSON)
+	if _, ok := parseConfigFlags(); ok {
+		t.Fatalf("Config with unknown flag must fail")
+	}
+
+	lines := strings.Split(strings.TrimSpace(bb.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Unexpected JSON error output: %q. Expected 2 lines", bb.String())
+	}
+	expected := []jsonConfigError{
+		{File: path, Line: 2, Key: "errorFormatMissing", Message: "unknown flag"},
+		{File: path, Line: 3, Key: "x", Message: "type annotation [int] isn't allowed for the registered flag"},
+	}
+	for i, line := range lines {
+		var e jsonConfigError
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Cannot parse JSON error %q: %s", line, err)
+		}
+		if e != expected[i] {
+			t.Fatalf("Unexpected error %+v. Expected %+v", e, expected[i])
+		}
+	}
+
+	// syntax errors are reported without line
+	if err := os.WriteFile(path, []byte("no separator\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	bb.Reset()
+	if _, ok := parseConfigFlags(); ok {
+		t.Fatalf("Config with syntax error must fail")
+	}
+	var e jsonConfigError
+	if err := json.Unmarshal(bb.Bytes(), &e); err != nil {
+		t.Fatalf("Cannot parse JSON error %q: %s", bb.String(), err)
+	}
+	if e.File != path || !strings.Contains(e.Message, "cannot split line 1") {
+		t.Fatalf("Unexpected error %+v", e)
+	}
+}
@@ -0,0 +1,317 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileRef is a file referenced by flag value in the form "@/path/to/file".
+type fileRef struct {
+	path    string
+	content string
+}
+
+var (
+	fileRefs               = make(map[string]fileRef) // Maps flag name to the file referenced by its value
+	fileRefsMu             sync.Mutex
+	fileRefsWatcherRunning bool // protected by fileRefsMu
+
+	allowFileRefs         bool
+	fileRefsCheckInterval = 10 * time.Second
+)
+
+// SetAllowFileRefs enables flag values referencing files in the form
+// "@/path/to/file", "@./path" or "@../path".
+//
+// Such values are replaced with the contents of the referenced files
+// without the trailing newline. Relative paths are resolved against
+// the directory of the config file containing the value, or against
+// the current working directory for values from environment variables
+// and SetOverrides(). Referenced files are checked for changes as described
+// at SetFileRefsCheckInterval().
+//
+// File references are disabled by default, so values such as "@./foo"
+// are applied verbatim. Must be called before Parse().
+func SetAllowFileRefs(allow bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetAllowFileRefs() must be called before Parse()")
+	}
+	allowFileRefs = allow
+}
+
+// isFileRef returns true if v references a file in the form "@/path",
+// "@./path" or "@../path" and file references are enabled
+// via SetAllowFileRefs().
+//
+// Other values starting with '@' such as "@channel" aren't file references.
+func isFileRef(v string) bool {
+	if !allowFileRefs {
+		return false
+	}
+	return strings.HasPrefix(v, "@/") || strings.HasPrefix(v, "@./") || strings.HasPrefix(v, "@../")
+}
+
+// fileRefPath returns the path to the file referenced by v, which is read
+// from the config at configPath.
+//
+// Relative paths are resolved against the directory of the config. They are
+// left as is if configPath is empty or isn't a local file, so they are
+// resolved against the current working directory.
+func fileRefPath(v, configPath string) string {
+	path := v[1:]
+	if strings.HasPrefix(v, "@/") || configPath == "" || configPath == defaultConfigName || isURL(configPath) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configPath), path)
+}
+
+// readFileRef returns the contents of the referenced file at path
+// without the trailing newline.
+func readFileRef(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read referenced file [%s]: %s", path, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveFileRef resolves the value for the flag f read from the config
+// at configPath if it references a file and tracks the referenced file
+// for changes.
+func resolveFileRef(f *flag.Flag, v, configPath string) (string, error) {
+	fileRefsMu.Lock()
+	defer fileRefsMu.Unlock()
+
+	if !isFileRef(v) {
+		delete(fileRefs, f.Name)
+		return v, nil
+	}
+	path := fileRefPath(v, configPath)
+	content, err := readFileRef(path)
+	if err != nil {
+		return "", err
+	}
+	fileRefs[f.Name] = fileRef{
+		path:    path,
+		content: content,
+	}
+	if !fileRefsWatcherRunning && fileRefsCheckInterval > 0 {
+		fileRefsWatcherRunning = true
+		go fileRefsWatcher()
+	}
+	return content, nil
+}
+
+// snapshotFileRefs returns a copy of fileRefs for restoring it
+// via restoreFileRefs() if config cannot be applied.
+func snapshotFileRefs() map[string]fileRef {
+	fileRefsMu.Lock()
+	defer fileRefsMu.Unlock()
+
+	m := make(map[string]fileRef, len(fileRefs))
+	for flagName, ref := range fileRefs {
+		m[flagName] = ref
+	}
+	return m
+}
+
+func restoreFileRefs(m map[string]fileRef) {
+	fileRefsMu.Lock()
+	fileRefs = m
+	fileRefsMu.Unlock()
+}
+
+// pruneFileRefs stops tracking files referenced by flags, which have been
+// removed from the config.
+//
+// configFlags contains flags found in the config. References set via
+// environment variables or SetOverrides() are kept.
+func pruneFileRefs(configFlags map[string]bool) {
+	fileRefsMu.Lock()
+	defer fileRefsMu.Unlock()
+
+	for flagName := range fileRefs {
+		if !configFlags[flagName] && !envFlags[flagName] && !overrideFlags[flagName] {
+			delete(fileRefs, flagName)
+		}
+	}
+}
+
+// SetFileRefsCheckInterval sets the interval for checking files referenced
+// by flag values in the form "@/path/to/file" for changes.
+//
+// Flags referencing changed files are updated without full config reload,
+// so rotated secrets and certificates are propagated quickly. Callbacks
+// registered via OnFlagChange() are called for the updated flags.
+// The checks run only while flag values reference files.
+// The default interval is 10 seconds. Zero interval disables the checks.
+func SetFileRefsCheckInterval(interval time.Duration) {
+	if parsed {
+		logger.Panicf("iniflags: SetFileRefsCheckInterval() must be called before Parse()")
+	}
+	fileRefsCheckInterval = interval
+}
+
+// fileRefsWatcher checks referenced files for changes until no flags
+// reference files.
+func fileRefsWatcher() {
+	for {
+		time.Sleep(fileRefsCheckInterval)
+		fileRefsMu.Lock()
+		if len(fileRefs) == 0 {
+			fileRefsWatcherRunning = false
+			fileRefsMu.Unlock()
+			return
+		}
+		fileRefsMu.Unlock()
+		checkFileRefs()
+	}
+}
+
+// checkFileRefs updates flags referencing changed files.
+func checkFileRefs() {
+	reloadMu.Lock()
+	changes := updateFileRefFlags()
+	reloadMu.Unlock()
+
+	// Callbacks are called without holding reloadMu,
+	// so they may trigger config reloads or set flags.
+	if changes != nil {
+		changes.issue()
+	}
+}
+
+// updateFileRefFlags updates flags referencing changed files and returns
+// the changes to notify callbacks about. It returns nil if no flags change.
+//
+// fileRefsMu isn't held while the flags are updated, since resolveFileRef()
+// is called with the mutex passed to SetReloadMutex() held.
+//
+// reloadMu must be held by the caller.
+func updateFileRefFlags() *flagChanges {
+	refs := snapshotFileRefs()
+	updatedRefs := make(map[string]fileRef)
+	oldFlagValues := make(map[string]string)
+	lockFlagsMutex()
+	for flagName, ref := range refs {
+		content, err := readFileRef(ref.path)
+		if err != nil {
+			logger.Printf("iniflags: %s", err)
+			continue
+		}
+		if content == ref.content {
+			continue
+		}
+		if !IsReloadable(flagName) {
+			logger.Printf("iniflags: ignoring new contents of file [%s] for non-reloadable flag [%s]; restart the app to apply it", ref.path, flagName)
+			continue
+		}
+		f := flag.Lookup(flagName)
+		oldValue := f.Value.String()
+		if err := setFlagValue(f, content); err != nil {
+			restoreFlagValue(f, oldValue)
+			logger.Printf("iniflags: cannot set flag [%s] to the contents of file [%s]: [%s]", flagName, ref.path, redactError(flagName, err, content))
+			continue
+		}
+		updatedRefs[flagName] = fileRef{
+			path:    ref.path,
+			content: content,
+		}
+		if oldValue != f.Value.String() {
+			oldFlagValues[flagName] = oldValue
+		}
+		if traceHook != nil {
+			traceHook("file", flagName, redactValue(flagName, f.Value.String()))
+		}
+	}
+	unlockFlagsMutex()
+
+	fileRefsMu.Lock()
+	for flagName, ref := range updatedRefs {
+		// Skip flags, which stopped referencing the file in the meantime.
+		if fileRefs[flagName].path == ref.path {
+			fileRefs[flagName] = ref
+		}
+	}
+	fileRefsMu.Unlock()
+
+	if len(oldFlagValues) == 0 {
+		return nil
+	}
+	changed := make([]string, 0, len(oldFlagValues))
+	for flagName := range oldFlagValues {
+		changed = append(changed, flagName)
+	}
+	sort.Strings(changed)
+	logger.Printf("iniflags: updated flags referencing changed files: %v", changed)
+	return newFlagChanges(TriggerFileChange, oldFlagValues)
+}
+
+// SetFlagFromFile sets the flag with the given name to the contents
+// of the file at filePath with trailing whitespace trimmed.
+//
+// Unlike @file references in config files, the file is read only once
+// at the time of the call. This allows applying rotated secrets such as
+// TLS certificates on demand, e.g. from a file watcher. The current flag
+// value is preserved if the file cannot be read or the value is invalid.
+// Callbacks registered via OnFlagChange() are called if the value changes
+// after Parse(). The file previously referenced by the flag value
+// in the form "@/path/to/file" is no longer tracked for changes.
+func SetFlagFromFile(flagName, filePath string) error {
+	if fullName, ok := flagShorthands[flagName]; ok {
+		flagName = fullName
+	}
+	f := flag.Lookup(flagName)
+	if f == nil {
+		return fmt.Errorf("iniflags: cannot set value for unknown flag [%s]", flagName)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("iniflags: cannot read file [%s] for flag [%s]: %s", filePath, flagName, err)
+	}
+	content := strings.TrimRight(string(data), " \t\r\n")
+
+	reloadMu.Lock()
+	lockFlagsMutex()
+	oldValue := f.Value.String()
+	err = setFlagValue(f, content)
+	if err != nil {
+		restoreFlagValue(f, oldValue)
+	}
+	unlockFlagsMutex()
+	if err != nil {
+		reloadMu.Unlock()
+		return fmt.Errorf("iniflags: cannot set flag [%s] to the contents of file [%s]: [%s]", flagName, filePath, redactError(flagName, err, content))
+	}
+
+	// The flag no longer references the file, which has been previously
+	// referenced by its value, so the file mustn't overwrite the new value.
+	fileRefsMu.Lock()
+	delete(fileRefs, flagName)
+	fileRefsMu.Unlock()
+
+	if traceHook != nil {
+		traceHook("file", flagName, redactValue(flagName, f.Value.String()))
+	}
+	var changes *flagChanges
+	if parsed && oldValue != f.Value.String() {
+		oldFlagValues := map[string]string{
+			flagName: oldValue,
+		}
+		changes = newFlagChanges(TriggerFlagSet, oldFlagValues)
+	}
+	reloadMu.Unlock()
+
+	// Callbacks are called without holding reloadMu,
+	// so they may trigger config reloads or set flags.
+	if changes != nil {
+		changes.issue()
+	}
+	return nil
+}
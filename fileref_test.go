@@ -0,0 +1,198 @@
+package iniflags
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileRefs(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/token"
+	if err := os.WriteFile(path, []byte("secret1\n"), 0600); err != nil {
+		t.Fatalf("Cannot write file: %s", err)
+	}
+	configPath := dir + "/config.ini"
+	if err := os.WriteFile(configPath, []byte("x = @./token\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		delete(flagChangeCallbacks, "x")
+		restoreFileRefs(make(map[string]fileRef))
+		allowFileRefs = false
+	}()
+	*config = configPath
+
+	// file references are disabled by default
+	parsed = true
+	TriggerReload()
+	if *x != "@./token" {
+		t.Fatalf("Unexpected x=[%s]. Expected [@./token]", *x)
+	}
+	if len(snapshotFileRefs()) != 0 {
+		t.Fatalf("File references mustn't be tracked if they are disabled")
+	}
+
+	parsed = false
+	SetAllowFileRefs(true)
+	callbackCalls := 0
+	OnFlagChange("x", func() {
+		callbackCalls++
+	})
+
+	// relative paths are resolved against the config directory
+	parsed = true
+	TriggerReload()
+	if *x != "secret1" {
+		t.Fatalf("Unexpected x=[%s]. Expected [secret1]", *x)
+	}
+	fileRefsMu.Lock()
+	watcherRunning := fileRefsWatcherRunning
+	fileRefsMu.Unlock()
+	if !watcherRunning {
+		t.Fatalf("File references watcher must be started for referenced files")
+	}
+	callbackCalls = 0
+
+	checkFileRefs()
+	if callbackCalls != 0 {
+		t.Fatalf("Callbacks mustn't be called for unchanged files")
+	}
+	if err := os.WriteFile(path, []byte("secret2\n"), 0600); err != nil {
+		t.Fatalf("Cannot write file: %s", err)
+	}
+	checkFileRefs()
+	if *x != "secret2" {
+		t.Fatalf("Unexpected x=[%s] after file change. Expected [secret2]", *x)
+	}
+	if callbackCalls != 1 {
+		t.Fatalf("Unexpected number of callback calls: %d. Expected 1", callbackCalls)
+	}
+	if !isFileRef("@./token") || isFileRef("@channel") {
+		t.Fatalf("Unexpected file reference detection")
+	}
+
+	// failed reload mustn't change tracked files
+	if err := os.WriteFile(configPath, []byte("x = plain\nunknownFileRefsFlag = foo\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldAllowUnknownFlags := *allowUnknownFlags
+	*allowUnknownFlags = false
+	TriggerReload()
+	*allowUnknownFlags = oldAllowUnknownFlags
+	if ref, ok := snapshotFileRefs()["x"]; !ok || ref.path != path {
+		t.Fatalf("Unexpected file reference after failed reload: %+v. Expected reference to %s", ref, path)
+	}
+
+	// files aren't tracked after the flag is removed from the config
+	if err := os.WriteFile(configPath, []byte("\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	TriggerReload()
+	if len(snapshotFileRefs()) != 0 {
+		t.Fatalf("File references must be removed with the flag from the config")
+	}
+}
+
+func TestFileRefsCallbacksMaySetFlags(t *testing.T) {
+	path := t.TempDir() + "/token"
+	if err := os.WriteFile(path, []byte("secret2\n"), 0600); err != nil {
+		t.Fatalf("Cannot write file: %s", err)
+	}
+	oldX := *x
+	defer func() {
+		*x = oldX
+		delete(flagChangeCallbacks, "x")
+		restoreFileRefs(make(map[string]fileRef))
+	}()
+	*x = "secret1"
+	restoreFileRefs(map[string]fileRef{
+		"x": {path: path, content: "secret1"},
+	})
+
+	parsed = true
+	OnFlagChange("x", func() {
+		if err := SetFlagValueWithoutCallback("x", *x+"-fromCallback"); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		checkFileRefs()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Deadlock when setting flags from callbacks for changed files")
+	}
+	if *x != "secret2-fromCallback" {
+		t.Fatalf("Unexpected x=[%s]. Expected [secret2-fromCallback]", *x)
+	}
+	pendingCallbacks = make(map[string]string)
+}
+
+func TestSetFlagFromFile(t *testing.T) {
+	path := t.TempDir() + "/cert.pem"
+	if err := os.WriteFile(path, []byte("cert-v1\n\n"), 0644); err != nil {
+		t.Fatalf("Cannot write file: %s", err)
+	}
+	oldX := *x
+	defer func() {
+		*x = oldX
+		delete(flagChangeCallbacks, "x")
+		restoreFileRefs(make(map[string]fileRef))
+		pendingCallbacks = make(map[string]string)
+	}()
+	*x = "initial"
+	restoreFileRefs(map[string]fileRef{
+		"x": {path: path + ".old", content: "initial"},
+	})
+
+	callbackCalls := 0
+	parsed = false
+	OnFlagChange("x", func() {
+		callbackCalls++
+		// callbacks may set flags
+		if err := SetFlagValueWithoutCallback("x", *x+"-fromCallback"); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+	parsed = true
+
+	done := make(chan error)
+	go func() {
+		done <- SetFlagFromFile("x", path)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Deadlock when setting flags from callbacks")
+	}
+	if *x != "cert-v1-fromCallback" {
+		t.Fatalf("Unexpected x=[%s]. Expected [cert-v1-fromCallback]", *x)
+	}
+	if callbackCalls != 1 {
+		t.Fatalf("Unexpected number of callback calls: %d. Expected 1", callbackCalls)
+	}
+	if len(snapshotFileRefs()) != 0 {
+		t.Fatalf("The previously referenced file must be no longer tracked")
+	}
+
+	if err := SetFlagFromFile("x", path+".missing"); err == nil {
+		t.Fatalf("Expecting error for missing file")
+	}
+	if *x != "cert-v1-fromCallback" {
+		t.Fatalf("Flag value must be preserved on error; got x=[%s]", *x)
+	}
+	if err := SetFlagFromFile("unknownFlagFromFile", path); err == nil {
+		t.Fatalf("Expecting error for unknown flag")
+	}
+}
@@ -0,0 +1,34 @@
+package iniflags
+
+import "flag"
+
+// lookupFlag returns the registered flag for the given name or shorthand.
+func lookupFlag(name string) *flag.Flag {
+	if fullName, ok := flagShorthands[name]; ok {
+		name = fullName
+	}
+	return flag.Lookup(name)
+}
+
+// FlagUsage returns the usage text for the given flag or shorthand.
+//
+// Unlike GetFlagUsage(), the usage text isn't extended with shorthands.
+// An empty string is returned for unknown flags.
+func FlagUsage(name string) string {
+	f := lookupFlag(name)
+	if f == nil {
+		return ""
+	}
+	return f.Usage
+}
+
+// FlagDefault returns the default value for the given flag or shorthand.
+//
+// An empty string is returned for unknown flags.
+func FlagDefault(name string) string {
+	f := lookupFlag(name)
+	if f == nil {
+		return ""
+	}
+	return f.DefValue
+}
@@ -0,0 +1,25 @@
+package iniflags
+
+import "testing"
+
+func TestFlagUsageAndDefault(t *testing.T) {
+	defer delete(flagShorthands, "xu")
+	parsed = false
+	if err := RegisterShorthand("xu", "x"); err != nil {
+		t.Fatalf("Cannot register shorthand: %s", err)
+	}
+	for _, name := range []string{"x", "xu"} {
+		if s := FlagUsage(name); s != "for TestSetConfigFile" {
+			t.Fatalf("Unexpected usage for %q: %q", name, s)
+		}
+		if s := FlagDefault(name); s != "baz" {
+			t.Fatalf("Unexpected default for %q: %q. Expected \"baz\"", name, s)
+		}
+	}
+	if s := FlagUsage("missingFlag"); s != "" {
+		t.Fatalf("Unexpected usage for unknown flag: %q", s)
+	}
+	if s := FlagDefault("missingFlag"); s != "" {
+		t.Fatalf("Unexpected default for unknown flag: %q", s)
+	}
+}
@@ -0,0 +1,99 @@
+package iniflags
+
+import (
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Format parses config files in a custom format.
+type Format interface {
+	// Parse reads flag values from r containing the config file
+	// at the given path.
+	Parse(r io.Reader, path string) ([]FlagArg, error)
+}
+
+// FormatFunc is an adapter allowing the use of ordinary functions as Format.
+type FormatFunc func(r io.Reader, path string) ([]FlagArg, error)
+
+// Parse calls f(r, path).
+func (f FormatFunc) Parse(r io.Reader, path string) ([]FlagArg, error) {
+	return f(r, path)
+}
+
+type iniFormat struct{}
+
+func (iniFormat) Parse(r io.Reader, path string) ([]FlagArg, error) {
+	return newConfigParser().parseReader(path, r)
+}
+
+// IniFormat is the built-in ini parser.
+//
+// It is used for config files with extensions lacking a Format registered
+// via RegisterFormat(). It may be registered for additional extensions,
+// e.g. RegisterFormat(".conf", IniFormat).
+var IniFormat Format = iniFormat{}
+
+var (
+	configFormats = make(map[string]Format)
+	configFormat  string
+)
+
+// normalizeExt returns lowercase ext with leading dot.
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if ext != "" && ext[0] != '.' {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// RegisterFormat registers f for parsing config files with the given
+// extension, e.g. ".json".
+//
+// Config files, including imported ones, are dispatched to formats
+// by their extension, unless a format is set explicitly via
+// SetConfigFormat(). SetPreprocessor(), SetTOMLFlagsTable() and other
+// ini-specific settings aren't applied to files parsed by custom formats.
+// Must be called before Parse().
+func RegisterFormat(ext string, f Format) {
+	if parsed {
+		logger.Panicf("iniflags: RegisterFormat() must be called before Parse()")
+	}
+	configFormats[normalizeExt(ext)] = f
+}
+
+// SetConfigFormat sets the extension of the format registered via
+// RegisterFormat(), which is used for all the config files regardless
+// of their extensions.
+//
+// Pass ".ini" for the built-in ini parser. Must be called before Parse().
+func SetConfigFormat(ext string) {
+	if parsed {
+		logger.Panicf("iniflags: SetConfigFormat() must be called before Parse()")
+	}
+	configFormat = normalizeExt(ext)
+}
+
+// configExt returns lowercase extension of the given config path or url.
+func configExt(configPath string) string {
+	if isURL(configPath) {
+		if u, err := url.Parse(configPath); err == nil {
+			configPath = u.Path
+		}
+	}
+	return strings.ToLower(path.Ext(configPath))
+}
+
+// lookupFormat returns the format for parsing the config file at the given path.
+func (p *configParser) lookupFormat(configPath string) Format {
+	ext := p.format
+	if ext == "" {
+		ext = configExt(configPath)
+	}
+	if f, ok := p.formats[ext]; ok && f != nil {
+		return f
+	}
+	return IniFormat
+}
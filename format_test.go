@@ -0,0 +1,112 @@
+package iniflags
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// kvFormat parses "key: value" lines.
+var kvFormat = FormatFunc(func(r io.Reader, path string) ([]FlagArg, error) {
+	var args []FlagArg
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		n := strings.IndexByte(s.Text(), ':')
+		if n < 0 {
+			return nil, fmt.Errorf("missing colon at line %d", lineNum)
+		}
+		args = append(args, FlagArg{
+			Key:     strings.TrimSpace(s.Text()[:n]),
+			Value:   strings.TrimSpace(s.Text()[n+1:]),
+			LineNum: lineNum,
+		})
+	}
+	return args, s.Err()
+})
+
+func TestRegisterFormat(t *testing.T) {
+	dir := t.TempDir()
+	kvPath := dir + "/config.kv"
+	if err := os.WriteFile(kvPath, []byte("x: from-kv\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	iniPath := dir + "/config.ini"
+	if err := os.WriteFile(iniPath, []byte("#import \"config.kv\"\ny = 1\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	defer func() {
+		configFormats = make(map[string]Format)
+	}()
+	parsed = false
+	RegisterFormat("KV", kvFormat)
+	parsed = true
+
+	args, ok := getArgsFromConfig(iniPath)
+	if !ok {
+		t.Fatalf("Cannot read config at %q", iniPath)
+	}
+	if len(args) != 2 {
+		t.Fatalf("Unexpected number of args: %+v. Expected 2", args)
+	}
+	if args[0].Key != "x" || args[0].Value != "from-kv" || args[0].FilePath != kvPath || args[0].LineNum != 1 {
+		t.Fatalf("Unexpected arg read from %q: %+v", kvPath, args[0])
+	}
+	if args[1].Key != "y" || args[1].Value != "1" || args[1].FilePath != iniPath {
+		t.Fatalf("Unexpected arg read from %q: %+v", iniPath, args[1])
+	}
+
+	// parse errors are reported with the config path
+	if err := os.WriteFile(kvPath, []byte("x = from-kv\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	_, err := newConfigParser().parseFile(kvPath)
+	if err == nil || !strings.Contains(err.Error(), kvPath) {
+		t.Fatalf("Unexpected error: %v. Expected error mentioning %q", err, kvPath)
+	}
+}
+
+func TestSetConfigFormat(t *testing.T) {
+	path := t.TempDir() + "/config"
+	if err := os.WriteFile(path, []byte("x: from-kv\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	defer func() {
+		configFormats = make(map[string]Format)
+		configFormat = ""
+	}()
+	parsed = false
+	RegisterFormat(".kv", kvFormat)
+	SetConfigFormat("kv")
+	parsed = true
+
+	args, ok := getArgsFromConfig(path)
+	if !ok {
+		t.Fatalf("Cannot read config at %q", path)
+	}
+	if len(args) != 1 || args[0].Key != "x" || args[0].Value != "from-kv" {
+		t.Fatalf("Unexpected args read from %q: %+v", path, args)
+	}
+
+	// the built-in ini parser may be selected explicitly
+	configFormat = ".ini"
+	if _, ok := getArgsFromConfig(path); ok {
+		t.Fatalf("Expecting error when parsing %q as ini", path)
+	}
+}
+
+func TestConfigExt(t *testing.T) {
+	f := func(configPath, expected string) {
+		t.Helper()
+		if ext := configExt(configPath); ext != expected {
+			t.Fatalf("Unexpected extension for %q: %q. Expected %q", configPath, ext, expected)
+		}
+	}
+	f("/etc/app/config.ini", ".ini")
+	f("config.JSON", ".json")
+	f("/etc/app/config", "")
+	f("https://example.com/config.yaml?version=2", ".yaml")
+	f("file:///etc/app/config.toml", ".toml")
+}
@@ -0,0 +1,62 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+)
+
+var (
+	frozenFlags   map[string]bool // names of flags frozen via Freeze()
+	frozenFlagsMu sync.Mutex
+)
+
+// checkFrozen returns an error if the flag f has been frozen via Freeze().
+//
+// Only flags registered in flag.CommandLine may be frozen, so flags with
+// the same names in other flag sets aren't affected.
+func checkFrozen(f *flag.Flag) error {
+	frozenFlagsMu.Lock()
+	frozen := frozenFlags[f.Name]
+	frozenFlagsMu.Unlock()
+	if !frozen || flag.Lookup(f.Name) != f {
+		return nil
+	}
+	return fmt.Errorf("iniflags: cannot set flag [%s], since flags are frozen; call Unfreeze() first", f.Name)
+}
+
+// Freeze makes all the flags read-only.
+//
+// After Freeze() call SetFlagValueWithoutCallback(), config reloads and
+// other iniflags functions setting flags fail to change flag values until
+// Unfreeze() is called. This helps catching stray code modifying flags
+// outside of the managed config reload. Flag values aren't wrapped,
+// so direct flag.Set() calls aren't rejected, while type assertions
+// on flag values keep working.
+//
+// It must be called after Parse().
+func Freeze() {
+	if !parsed {
+		logger.Panicf("iniflags: Freeze() must be called after Parse()")
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	m := make(map[string]bool)
+	flag.VisitAll(func(f *flag.Flag) {
+		m[f.Name] = true
+	})
+	frozenFlagsMu.Lock()
+	frozenFlags = m
+	frozenFlagsMu.Unlock()
+}
+
+// Unfreeze makes flags frozen via Freeze() writable again.
+func Unfreeze() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	frozenFlagsMu.Lock()
+	frozenFlags = nil
+	frozenFlagsMu.Unlock()
+}
@@ -0,0 +1,52 @@
+package iniflags
+
+import (
+	"flag"
+	"testing"
+)
+
+var frozenSlice = StringSlice("frozenSlice", nil, "flag for TestFreeze")
+
+func TestFreeze(t *testing.T) {
+	oldX := *x
+	defer func() {
+		Unfreeze()
+		*x = oldX
+	}()
+
+	parsed = true
+	Freeze()
+	if err := SetFlagValueWithoutCallback("x", "frozen"); err == nil {
+		t.Fatalf("Expecting error when setting frozen flag")
+	}
+	if *x != oldX {
+		t.Fatalf("Frozen flag value mustn't change; got [%s]", *x)
+	}
+	if flagKind(flag.Lookup("x")) != KindString {
+		t.Fatalf("Frozen flag must retain its kind")
+	}
+
+	// frozen flags keep their values, so type assertions work
+	if _, ok := flag.Lookup("frozenSlice").Value.(sliceValue); !ok {
+		t.Fatalf("Frozen flag value must implement sliceValue")
+	}
+	if err := SetFlagValueWithoutCallback("frozenSlice", "[a, b]"); err == nil {
+		t.Fatalf("Expecting error when setting frozen slice flag")
+	}
+	if len(*frozenSlice) != 0 {
+		t.Fatalf("Frozen slice flag value mustn't change; got %q", *frozenSlice)
+	}
+
+	// flags with the same names in other flag sets aren't frozen
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("x", "", "")
+	if errs := ParseFlags("x = other\n", fs, "other.ini", nil); len(errs) > 0 {
+		t.Fatalf("Unexpected errors for flag set, which isn't frozen: %v", errs)
+	}
+
+	Unfreeze()
+	if err := SetFlagValueWithoutCallback("x", "unfrozen"); err != nil {
+		t.Fatalf("Unexpected error after Unfreeze(): %s", err)
+	}
+	pendingCallbacks = make(map[string]string)
+}
@@ -0,0 +1,20 @@
+package iniflags
+
+import "flag"
+
+// GetConfig returns values of all the registered flags keyed by flag names.
+//
+// Values are obtained via flag.Getter, so they have native types,
+// e.g. cfg["cacheSize"].(int) or cfg["timeout"].(time.Duration).
+// String representation is returned for flags not implementing flag.Getter.
+func GetConfig() map[string]interface{} {
+	cfg := make(map[string]interface{})
+	flag.VisitAll(func(f *flag.Flag) {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			cfg[f.Name] = getter.Get()
+		} else {
+			cfg[f.Name] = f.Value.String()
+		}
+	})
+	return cfg
+}
@@ -0,0 +1,30 @@
+package iniflags
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+type plainValue string
+
+func (v *plainValue) String() string     { return string(*v) }
+func (v *plainValue) Set(s string) error { *v = plainValue(s); return nil }
+
+func TestGetConfig(t *testing.T) {
+	flag.Int("getConfigCacheSize", 128, "flag for TestGetConfig")
+	flag.Duration("getConfigTimeout", time.Second, "flag for TestGetConfig")
+	pv := plainValue("plain")
+	flag.Var(&pv, "getConfigPlain", "flag for TestGetConfig")
+
+	cfg := GetConfig()
+	if n, ok := cfg["getConfigCacheSize"].(int); !ok || n != 128 {
+		t.Fatalf("Unexpected getConfigCacheSize=%#v. Expected 128", cfg["getConfigCacheSize"])
+	}
+	if d, ok := cfg["getConfigTimeout"].(time.Duration); !ok || d != time.Second {
+		t.Fatalf("Unexpected getConfigTimeout=%#v. Expected 1s", cfg["getConfigTimeout"])
+	}
+	if s, ok := cfg["getConfigPlain"].(string); !ok || s != "plain" {
+		t.Fatalf("Unexpected getConfigPlain=%#v. Expected \"plain\"", cfg["getConfigPlain"])
+	}
+}
@@ -1,3 +1,15 @@
 module github.com/boomhut/iniflags
 
 go 1.19
+
+require (
+	github.com/zalando/go-keyring v0.2.3
+	google.golang.org/protobuf v1.33.0
+)
+
+require (
+	github.com/alessio/shellescape v1.4.1 // indirect
+	github.com/danieljoos/wincred v1.2.0 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
+	golang.org/x/sys v0.8.0 // indirect
+)
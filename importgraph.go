@@ -0,0 +1,53 @@
+package iniflags
+
+import "path/filepath"
+
+// ImportNode describes a config file in the import graph
+// returned by ImportGraph().
+type ImportNode struct {
+	// Path is the path to the config file.
+	//
+	// Local paths are absolute, while http and https urls are left as is.
+	Path string
+
+	// Line is the line number of the #import directive
+	// in the importing file. It is zero for the root file.
+	Line int
+
+	// Cycle is set if the file is already being imported higher
+	// in the graph. Imports of such a file aren't expanded.
+	Cycle bool
+
+	// Imports contains files imported from the file in the order
+	// of #import directives.
+	Imports []*ImportNode
+}
+
+// ImportGraph returns the tree of config files imported from the config file
+// at the given path.
+//
+// Config files are parsed according to the global iniflags settings,
+// but flag values aren't applied. Import cycles are marked in the returned
+// tree via ImportNode.Cycle instead of returning an error.
+func ImportGraph(path string) (*ImportNode, error) {
+	p := newConfigParser()
+	p.allowMissing = false
+	root := newImportNode(path, 0)
+	p.importGraph = root
+	if _, err := p.parseFile(path); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func newImportNode(path string, line int) *ImportNode {
+	if !isURL(path) {
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+	}
+	return &ImportNode{
+		Path: path,
+		Line: line,
+	}
+}
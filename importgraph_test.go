@@ -0,0 +1,55 @@
+package iniflags
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportGraph(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.ini":   "# comment\n#import \"base.ini\"\nx = 1\n#import \"extra.ini\"\n",
+		"base.ini":   "#import \"common.ini\"\n",
+		"extra.ini":  "#import \"main.ini\"\n",
+		"common.ini": "y = 2\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Cannot write %s: %s", name, err)
+		}
+	}
+	oldAllowMissing := *allowMissingConfig
+	defer func() { *allowMissingConfig = oldAllowMissing }()
+	*allowMissingConfig = true
+
+	root, err := ImportGraph(filepath.Join(dir, "main.ini"))
+	if err != nil {
+		t.Fatalf("Cannot build import graph: %s", err)
+	}
+
+	checkNode := func(node *ImportNode, name string, line int, cycle bool, imports int) {
+		t.Helper()
+		if node.Path != filepath.Join(dir, name) {
+			t.Fatalf("Unexpected path %q. Expected %q", node.Path, filepath.Join(dir, name))
+		}
+		if node.Line != line {
+			t.Fatalf("Unexpected line %d for %q. Expected %d", node.Line, name, line)
+		}
+		if node.Cycle != cycle {
+			t.Fatalf("Unexpected cycle=%v for %q. Expected %v", node.Cycle, name, cycle)
+		}
+		if len(node.Imports) != imports {
+			t.Fatalf("Unexpected number of imports for %q: %d. Expected %d", name, len(node.Imports), imports)
+		}
+	}
+	checkNode(root, "main.ini", 0, false, 2)
+	checkNode(root.Imports[0], "base.ini", 2, false, 1)
+	checkNode(root.Imports[0].Imports[0], "common.ini", 1, false, 0)
+	checkNode(root.Imports[1], "extra.ini", 4, false, 1)
+	checkNode(root.Imports[1].Imports[0], "main.ini", 1, true, 0)
+
+	if _, err := ImportGraph(filepath.Join(dir, "missing.ini")); err == nil {
+		t.Fatalf("Expecting error for missing config file")
+	}
+}
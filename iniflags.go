@@ -1,44 +1,126 @@
+// Package iniflags combines standard flags with ini config files.
+//
+// Call Parse() instead of flag.Parse() for reading flag values from config
+// files. Config files to load are selected in the following order:
+//
+//   - -config passed via command line;
+//   - INIFLAGS_CONFIG environment variable;
+//   - files added via AddConfigFile();
+//   - -config default set via SetConfigFile();
+//   - urls set via SetConfigURLs();
+//   - content set via SetDefaultConfigContent().
+//
+// Only the first non-empty source is used. -config, INIFLAGS_CONFIG
+// and SetConfigFile() may contain multiple paths separated
+// by os.PathListSeparator.
 package iniflags
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"path"
+	"runtime"
+	"runtime/debug"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
 var (
-	allowUnknownFlags      = flag.Bool("allowUnknownFlags", false, "Don't terminate the application if ini file contains unknown flags.")
-	allowMissingConfig     = flag.Bool("allowMissingConfig", false, "Don't terminate the application if the ini file cannot be read.")
-	config                 = flag.String("config", "", "Path to ini config. May be relative to the current executable path.")
-	configUpdateInterval   = flag.Duration("configUpdateInterval", 0, "Update interval for re-reading config file set via -config flag. Zero disables config file re-reading.")
-	dumpflags              = flag.Bool("dumpflags", false, "Dumps values for all flags defined in the application into stdout in ini-compatible syntax and terminates the app.")
-	unsecure               = flag.Bool("unsecure", false, "Allow unsecure communication with the server when loading config file via http.")
+	allowUnknownFlags    = flag.Bool("allowUnknownFlags", false, "Don't terminate the application if ini file contains unknown flags.")
+	allowMissingConfig   = flag.Bool("allowMissingConfig", false, "Don't terminate the application if the ini file cannot be read.")
+	config               = flag.String("config", "", "Path to ini config. May be relative to the directory of the current executable. Multiple configs may be separated by the OS path list separator; later configs override earlier ones.")
+	configUpdateInterval = flag.Duration("configUpdateInterval", 0, "Update interval for re-reading config file set via -config flag. Zero disables config file re-reading.")
+	dumpflags            = flag.Bool("dumpflags", false, "Dumps values for all flags defined in the application into stdout in ini-compatible syntax and terminates the app.")
+	dumpchanged          = flag.Bool("dumpchanged", false, "Dumps values for flags differing from their defaults into stdout in ini-compatible syntax and terminates the app.")
+	unsecure             = flag.Bool("unsecure", false, "Allow unsecure communication with the server when loading config file via http. "+
+		"Values such as passwords or API keys in configs loaded via plain http could be intercepted, so use https in production.")
 	originalUsage          = flag.Usage // Store the original usage function
 	flagsToExcludeFromDump = map[string]bool{
 		"config":               true,
 		"dumpflags":            true,
+		"dumpchanged":          true,
 		"allowUnknownFlags":    true,
 		"allowMissingConfig":   true,
 		"configUpdateInterval": true,
 		"unsecure":             true,
+		logLevelFlagName:       true,
 	}
 )
 
 var (
 	flagChangeCallbacks   = make(map[string][]FlagChangeCallback)
-	importStack           []string
+	flagEventCallbacks    = make(map[string][]FlagChangeEventCallback)
+	configReloadCallbacks []ConfigReloadCallback
+	reloadMu              sync.Mutex             // serializes config reloads
+	flagGenerations       = make(map[string]int) // Maps flag name to the Generation it was last changed at
+	flagGenerationsMu     sync.Mutex
+	configURLs            []string
+	loadedConfigPaths     []string
+	preprocessor          func(raw []byte) ([]byte, error)
+	flagTrimCutsets       = make(map[string][]string)
+	allFlagsTrimCutsets   []string
+	tomlFlagsTable        string
+	httpUserAgent         = "iniflags/" + moduleVersion() + " (github.com/boomhut/iniflags)"
+	reloading             bool  // set while the config is re-read after Parse()
+	cacheBusting          *bool // overrides the default cache busting behaviour if set
+	skipInvalidValues     bool
+	traceHook             func(source, key, value string)
+	configFlags           = make(map[string]bool) // Flags found in the last successfully loaded config
+	parsedAt              time.Time
+	reloadedAt            time.Time
+	reloadedAtMu          sync.Mutex
+	configSectionFilter   map[string]bool
+	environment           string
+	rejectControlChars    bool
+	pendingCallbacks      = make(map[string]string)            // Old values for flags changed via SetFlagValueWithoutCallback()
+	commandShorthands     = make(map[string]map[string]string) // Maps command name to shorthands registered via RegisterShorthandIn()
+	shorthandMode         ShorthandMode
+	configPathMapper      func(rawPath string) string
+	nonReloadableFlags    = make(map[string]bool) // Flags marked via SetReloadable(name, false)
+	nonReloadableFlagsMu  sync.Mutex
+	parseCtx              context.Context         // Context for fetching configs during ParseWithContext()
+	programPath           string                  // args[0] passed to Parse()
+	sensitiveFlags        = make(map[string]bool) // Flags holding sensitive values
+	assignmentSeparator   = "="
+	updateInterval        atomic.Int64 // Interval for periodic config reload in nanoseconds
+	updaterRunning        bool
+	updaterMu             sync.Mutex
+	updaterWakeCh         = make(chan struct{}, 1)
+	dumpFilter            func(name string) bool
+	lowercaseSections     bool
+	reloadExcludeFunc     func(name string) bool
+	addedConfigFiles      []string
+	confirmCallbackFlags  = make(map[string]bool)
+	ignoredConfigFlags    map[string]bool // non-reloadable flags with config values ignored by the last applyFlagArgs() call
+	generationChans       []chan int
+	generationChansMu     sync.Mutex
+	flagsMutex            *sync.RWMutex // Set via SetReloadMutex()
+	dumpCommentChar       = '#'
+	readyCallbacks        []func()
+	executablePath        string // set via SetExecutablePath()
+	lineTransform         func(line string, lineNum int, path string) string
+	defaultConfigContent  string
+	configReloadHooks     []func(path string, content []byte) ([]byte, error)
+	reloadFailureHandlers []func(err error, generation int)
+	capturingErrors       bool        // set while Parse() or a config reload captures config errors
+	firstConfigError      *ParseError // the first config error reported while capturingErrors is set
 	parsed                bool
 	flagShorthands        = make(map[string]string) // Maps shorthand name to full flag name
 	commandLineShorthands = make(map[string]bool)   // Tracks which shorthands are registered for command line use
@@ -47,7 +129,7 @@ var (
 // Generation is flags' generation number.
 //
 // It is modified on each flags' modification
-// via either -configUpdateInterval or SIGHUP.
+// via either -configUpdateInterval, SIGHUP or TriggerReload().
 var Generation int
 
 // Parse obtains flag values from config file set via -config.
@@ -56,59 +138,270 @@ var Generation int
 // them by values parsed from config file set via -config.
 //
 // Path to config file can also be set via SetConfigFile() before Parse() call.
+//
+// The application exits on errors. Use ParseE() for handling errors instead.
 func Parse() {
 	if parsed {
 		logger.Panicf("iniflags: duplicate call to iniflags.Parse() detected")
 	}
+	err := ParseE()
+	switch {
+	case err == ErrFlagsDumped:
+		os.Exit(0)
+	case errors.Is(err, ErrConfigLoad):
+		// The error has been already logged.
+		os.Exit(1)
+	case err != nil:
+		reportConfigError(ParseError{Err: err}, err.Error())
+		os.Exit(1)
+	}
+}
+
+// ParseE works like Parse(), but returns an error instead of exiting
+// the application.
+//
+// ErrFlagsDumped is returned after dumping flags because of -dumpflags
+// or -dumpchanged, while an error wrapping ErrConfigLoad is returned
+// if config files cannot be loaded. Config reloads via SIGHUP and -configUpdateInterval are started
+// only if nil is returned.
+//
+// ParseE may be called again after an error other than ErrFlagsDumped,
+// e.g. after the config file becomes available. Functions, which must be
+// called after Parse(), treat flags as unparsed until ParseE succeeds.
+//
+// Invalid command-line args are handled according to the error handling
+// of flag.CommandLine, so the application exits on them by default.
+// See ParseWithContext() for obtaining these errors instead.
+func ParseE() error {
+	return ParseWithContext(context.Background(), os.Args)
+}
+
+// ErrFlagsDumped is returned by ParseE() and ParseWithContext() when flags are dumped
+// to stdout because of -dumpflags or -dumpchanged command-line flag.
+//
+// The application should exit after receiving this error.
+var ErrFlagsDumped = errors.New("iniflags: flags are dumped because of -dumpflags")
+
+// ErrConfigLoad is returned by ParseE() and ParseWithContext() when config
+// cannot be loaded. Error details are written to the log.
+//
+// The returned error wraps the first *ParseError found in the config
+// if there is such an error, so use errors.Is() for checking for ErrConfigLoad
+// and errors.As() for obtaining the *ParseError.
+var ErrConfigLoad = errors.New("iniflags: cannot load config; see the log for details")
+
+// configLoadError is the error returned when config cannot be loaded
+// because of the error found in the config.
+//
+// It wraps the *ParseError for the found error and is reported
+// as ErrConfigLoad by errors.Is().
+type configLoadError struct {
+	op string // "load" or "reload"
+	pe *ParseError
+}
+
+func (e *configLoadError) Error() string {
+	return fmt.Sprintf("iniflags: cannot %s config: %s", e.op, e.pe)
+}
+
+func (e *configLoadError) Is(target error) bool {
+	return target == ErrConfigLoad
+}
+
+func (e *configLoadError) Unwrap() error {
+	return e.pe
+}
+
+// newConfigLoadError returns the error for the failed config load or reload
+// depending on op, which wraps pe if it isn't nil.
+func newConfigLoadError(op string, pe *ParseError) error {
+	if pe == nil {
+		return ErrConfigLoad
+	}
+	return &configLoadError{op: op, pe: pe}
+}
+
+// startCapturingErrors starts capturing the first config error
+// reported via reportConfigError().
+func startCapturingErrors() {
+	capturingErrors = true
+	firstConfigError = nil
+}
+
+// stopCapturingErrors stops capturing config errors and returns
+// the first captured error. It returns nil if no errors were reported.
+func stopCapturingErrors() *ParseError {
+	pe := firstConfigError
+	capturingErrors = false
+	firstConfigError = nil
+	return pe
+}
+
+// ParseWithContext works like Parse(), but obtains command-line flags
+// from args instead of os.Args and returns an error instead of exiting
+// the application.
+//
+// args must contain the program name at args[0] like os.Args. ctx bounds
+// fetching configs via http or https during the call. It doesn't affect
+// subsequent config reloads.
+//
+// Errors in command-line args are handled according to the error handling
+// of flag.CommandLine. Set flag.CommandLine to a FlagSet created with
+// flag.ContinueOnError before defining flags in order to obtain these errors.
+func ParseWithContext(ctx context.Context, args []string) error {
+	if parsed {
+		return fmt.Errorf("iniflags: duplicate call to iniflags.Parse() detected")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("iniflags: args must contain at least the program name")
+	}
+	return parse(ctx, expandCommandLineShorthands(args))
+}
 
+func parse(ctx context.Context, args []string) error {
 	// Set custom usage function to include shorthands
 	flag.Usage = customUsage
 
-	// Handle command-line shorthands before calling flag.Parse()
-	handleCommandLineShorthands()
-
-	parsed = true
-	flag.Parse()
-	_, ok := parseConfigFlags()
+	programPath = args[0]
+	if err := flag.CommandLine.Parse(args[1:]); err != nil {
+		return err
+	}
+	if sliceMergeCLIAndConfig {
+		if err := saveCLISliceItems(); err != nil {
+			return err
+		}
+	}
+	var validationErr error
+	flag.Visit(func(f *flag.Flag) {
+		if err := validateFlagValue(f); err != nil && validationErr == nil {
+			validationErr = fmt.Errorf("iniflags: invalid value [%s] for flag [%s] set via command line: %s", redactValue(f.Name, f.Value.String()), f.Name, err)
+		}
+	})
+	if validationErr != nil {
+		return validationErr
+	}
+	if traceHook != nil {
+		flag.Visit(func(f *flag.Flag) {
+			traceHook("command-line", f.Name, redactValue(f.Name, f.Value.String()))
+		})
+	}
+	if err := applyComputedDefaults(); err != nil {
+		return err
+	}
+	if err := parseProcessEnvFlags(); err != nil {
+		return err
+	}
+	if err := applyOverrides(); err != nil {
+		return err
+	}
+	parseCtx = ctx
+	startCapturingErrors()
+	oldFlagValues, ok := parseConfigFlags()
+	pe := stopCapturingErrors()
+	parseCtx = nil
 	if !ok {
-		os.Exit(1)
+		return newConfigLoadError("load", pe)
+	}
+	if err := validateFlagConstraints(); err != nil {
+		return err
 	}
 
+	// Flags are marked as parsed only after they are successfully obtained,
+	// so the caller may retry parsing after an error, e.g. when config
+	// becomes available.
+	parsed = true
 	if *dumpflags {
 		dumpFlags()
-		os.Exit(0)
+		return ErrFlagsDumped
+	}
+	if *dumpchanged {
+		DumpChangedFlags(os.Stdout)
+		return ErrFlagsDumped
 	}
 
+	// Move callbacks registered for shorthands before RegisterShorthand() call
+	// to full flag names.
+	for flagName, callbacks := range flagChangeCallbacks {
+		if fullName, ok := flagShorthands[flagName]; ok {
+			flagChangeCallbacks[fullName] = append(flagChangeCallbacks[fullName], callbacks...)
+			delete(flagChangeCallbacks, flagName)
+		}
+	}
+	for flagName, callbacks := range flagEventCallbacks {
+		if fullName, ok := flagShorthands[flagName]; ok {
+			flagEventCallbacks[fullName] = append(flagEventCallbacks[fullName], callbacks...)
+			delete(flagEventCallbacks, flagName)
+		}
+	}
 	for flagName := range flagChangeCallbacks {
 		verifyFlagChangeFlagName(flagName)
 	}
-	Generation++
-	issueAllFlagChangeCallbacks()
+	for flagName := range flagEventCallbacks {
+		verifyFlagChangeFlagName(flagName)
+	}
+	incGeneration()
+	changedFlags := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		changedFlags[f.Name] = f.DefValue
+	})
+	for k, v := range oldFlagValues {
+		changedFlags[k] = v
+	}
+	// Config values may override values from environment variables,
+	// so the default value is the old value for such flags.
+	for k := range envFlags {
+		changedFlags[k] = flag.Lookup(k).DefValue
+	}
+	for k := range overrideFlags {
+		changedFlags[k] = flag.Lookup(k).DefValue
+	}
+	recordFlagGenerations(changedFlags)
+	pendingCallbacks = make(map[string]string)
+	issueAllFlagChangeCallbacks(changedFlags)
+	issueConfigReloadCallbacks(TriggerInitialParse)
+	parsedAt = time.Now()
+	setReloadTime(parsedAt)
+	for _, f := range readyCallbacks {
+		f()
+	}
 
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGHUP)
+	setWatcherRunning()
 	go sighupHandler(ch)
 
-	go configUpdater()
+	setUpdateInterval(*configUpdateInterval)
+	return nil
 }
 
-// handleCommandLineShorthands processes command-line arguments and
-// replaces registered shorthands with their full flag names
-func handleCommandLineShorthands() {
+// expandCommandLineShorthands returns a copy of command-line args
+// with registered shorthands replaced by their full flag names.
+func expandCommandLineShorthands(osArgs []string) []string {
 	if len(commandLineShorthands) == 0 {
-		return
+		return osArgs
 	}
 
-	args := make([]string, 0, len(os.Args))
-	args = append(args, os.Args[0])
+	args := make([]string, 0, len(osArgs))
+	args = append(args, osArgs[0])
+
+	for i := 1; i < len(osArgs); i++ {
+		arg := osArgs[i]
 
-	for i := 1; i < len(os.Args); i++ {
-		arg := os.Args[i]
+		if arg == "--" {
+			// The remaining args aren't flags, so pass them through unchanged
+			args = append(args, osArgs[i:]...)
+			break
+		}
 
 		// Check if the argument is a shorthand flag
-		if len(arg) > 1 && arg[0] == '-' && !strings.HasPrefix(arg, "--") {
-			// Remove the leading dash
-			shortName := arg[1:]
+		if len(arg) > 1 && arg[0] == '-' {
+			// Remove the leading dashes. Both -shorthand and --shorthand
+			// forms are supported like in the flag package.
+			dashes := "-"
+			if strings.HasPrefix(arg, "--") {
+				dashes = "--"
+			}
+			shortName := arg[len(dashes):]
 
 			// If there's an equals sign, split it
 			value := ""
@@ -123,12 +416,12 @@ func handleCommandLineShorthands() {
 			if fullName, exists := flagShorthands[shortName]; exists && commandLineShorthands[shortName] {
 				// Replace with full name
 				if hasValue {
-					args = append(args, "-"+fullName+value)
+					args = append(args, dashes+fullName+value)
 				} else {
-					args = append(args, "-"+fullName)
+					args = append(args, dashes+fullName)
 					// If the next arg doesn't start with a dash, it's probably the value
-					if i+1 < len(os.Args) && !strings.HasPrefix(os.Args[i+1], "-") {
-						args = append(args, os.Args[i+1])
+					if i+1 < len(osArgs) && !strings.HasPrefix(osArgs[i+1], "-") {
+						args = append(args, osArgs[i+1])
 						i++
 					}
 				}
@@ -142,510 +435,2618 @@ func handleCommandLineShorthands() {
 		}
 	}
 
-	// Replace os.Args with the processed arguments
-	os.Args = args
+	return args
+}
+
+// setUpdateInterval sets the interval for periodic config reload
+// and (re)starts configUpdater if needed.
+func setUpdateInterval(interval time.Duration) {
+	updaterMu.Lock()
+	defer updaterMu.Unlock()
+
+	updateInterval.Store(int64(interval))
+	if !updaterRunning {
+		if interval > 0 {
+			updaterRunning = true
+			go configUpdater()
+		}
+		return
+	}
+	// Wake up the running configUpdater, so it applies the new interval.
+	select {
+	case updaterWakeCh <- struct{}{}:
+	default:
+	}
 }
 
 func configUpdater() {
-	if *configUpdateInterval != 0 {
-		for {
-			// Use time.Sleep() instead of time.Tick() for the sake of dynamic flag update.
-			time.Sleep(*configUpdateInterval)
-			updateConfig()
+	for {
+		updaterMu.Lock()
+		interval := time.Duration(updateInterval.Load())
+		if interval <= 0 {
+			updaterRunning = false
+			updaterMu.Unlock()
+			setNextCheck(time.Time{})
+			return
+		}
+		updaterMu.Unlock()
+
+		// Use timer instead of time.Tick() for the sake of dynamic interval update.
+		setNextCheck(time.Now().Add(interval))
+		t := time.NewTimer(interval)
+		select {
+		case <-t.C:
+			updateConfig(TriggerTimer)
+		case <-updaterWakeCh:
+			t.Stop()
 		}
 	}
 }
 
-func updateConfig() {
-	if oldFlagValues, ok := parseConfigFlags(); ok && len(oldFlagValues) > 0 {
+func updateConfig(trigger ReloadTrigger) {
+	reloadMu.Lock()
+	changes := reloadConfig(trigger)
+	reloadMu.Unlock()
+
+	// Callbacks are called without holding reloadMu,
+	// so they may trigger config reloads or set flags.
+	if changes != nil {
+		changes.issue()
+	}
+}
+
+// reloadConfig re-reads the config and returns the changes to notify
+// callbacks about. The returned changes contain the reload error
+// if the config cannot be loaded.
+//
+// reloadMu must be held by the caller.
+func reloadConfig(trigger ReloadTrigger) *flagChanges {
+	startTime := time.Now()
+	reloading = true
+	startCapturingErrors()
+	var oldFlagValues map[string]string
+	ok := false
+	if deltaFetcher != nil {
+		oldFlagValues, ok = applyConfigDelta()
+	}
+	if !ok {
+		// Errors found in the delta are superseded by errors in the full config.
+		firstConfigError = nil
+		oldFlagValues, ok = parseConfigFlags()
+	}
+	pe := stopCapturingErrors()
+	reloading = false
+	var reloadErr error
+	if !ok {
+		reloadErr = newConfigLoadError("reload", pe)
+	}
+	recordConfigCheck(startTime, reloadErr)
+	if !ok {
+		return &flagChanges{
+			reloadErr: reloadErr,
+		}
+	}
+	if interval := *configUpdateInterval; interval != time.Duration(updateInterval.Load()) {
+		// -configUpdateInterval has been changed in the config.
+		setUpdateInterval(interval)
+	}
+	changes := &flagChanges{
+		trigger:  trigger,
+		reloaded: true,
+	}
+	if len(oldFlagValues) > 0 {
 		modifiedFlags := make(map[string]string)
 		for k := range oldFlagValues {
-			modifiedFlags[k] = flag.Lookup(k).Value.String()
+			modifiedFlags[k] = redactValue(k, flag.Lookup(k).Value.String())
 		}
-		logger.Printf("iniflags: read updated config. Modified flags are: %v", modifiedFlags)
-		Generation++
-		issueFlagChangeCallbacks(oldFlagValues)
+		logger.Printf("iniflags: read updated config (trigger: %s). Modified flags are: %v", trigger, modifiedFlags)
+		changes = newFlagChanges(trigger, oldFlagValues)
+		changes.reloaded = true
 	}
+	changes.confirmed = confirmedFlags(trigger, oldFlagValues)
+	setReloadTime(time.Now())
+	return changes
 }
 
-// FlagChangeCallback is called when the given flag is changed.
+// flagChanges contains flag changes collected while reloadMu is held.
 //
-// The callback may be registered for any flag via OnFlagChange().
-type FlagChangeCallback func()
+// Callbacks for the changes are called via issue() after reloadMu
+// is unlocked.
+type flagChanges struct {
+	trigger   ReloadTrigger
+	events    []FlagChangeEvent
+	confirmed []FlagChangeEvent // events for flags confirmed by the reloaded config
+	reloaded  bool              // whether OnConfigReload() callbacks must be called
+	reloadErr error             // the error for the failed reload passed to OnConfigReloadFailure() handlers
+}
 
-// OnFlagChange registers the callback, which is called after the given flag
-// value is initialized and/or changed.
-//
-// Flag values are initialized during iniflags.Parse() call.
-// Flag value can be changed on config re-read after obtaining SIGHUP signal
-// or if periodic config re-read is enabled with -configUpdateInterval flag.
+// newFlagChanges increments Generation and returns changes for the flags
+// with the given old values.
 //
-// Note that flags set via command-line cannot be overriden via config file modifications.
-func OnFlagChange(flagName string, callback FlagChangeCallback) {
-	if parsed {
-		verifyFlagChangeFlagName(flagName)
+// It must be called after the flags are changed.
+func newFlagChanges(trigger ReloadTrigger, oldFlagValues map[string]string) *flagChanges {
+	incGeneration()
+	recordFlagGenerations(oldFlagValues)
+	names := make([]string, 0, len(oldFlagValues))
+	for name := range oldFlagValues {
+		names = append(names, name)
 	}
-	flagChangeCallbacks[flagName] = append(flagChangeCallbacks[flagName], callback)
+	sort.Strings(names)
+	changes := &flagChanges{
+		trigger: trigger,
+	}
+	for _, name := range names {
+		changes.events = append(changes.events, FlagChangeEvent{
+			Name:       name,
+			OldValue:   redactValue(name, oldFlagValues[name]),
+			NewValue:   redactValue(name, flag.Lookup(name).Value.String()),
+			Trigger:    trigger,
+			Generation: Generation,
+		})
+	}
+	return changes
 }
 
-func verifyFlagChangeFlagName(flagName string) {
-	if flag.Lookup(flagName) == nil {
-		logger.Fatalf("iniflags: cannot register FlagChangeCallback for non-existing flag [%s]", flagName)
+func (c *flagChanges) issue() {
+	for _, event := range c.events {
+		issueFlagChangeCallbacks(event)
+	}
+	for _, event := range c.confirmed {
+		issueFlagChangeCallbacks(event)
+	}
+	if c.reloaded {
+		issueConfigReloadCallbacks(c.trigger)
+	}
+	if c.reloadErr != nil {
+		issueReloadFailureHandlers(c.reloadErr)
 	}
 }
 
-func issueFlagChangeCallbacks(oldFlagValues map[string]string) {
-	for flagName := range oldFlagValues {
-		if fs, ok := flagChangeCallbacks[flagName]; ok {
-			for _, f := range fs {
-				f()
+// ParseTime returns the time Parse() completed at.
+//
+// It returns zero time if Parse() wasn't called yet.
+func ParseTime() time.Time {
+	return parsedAt
+}
+
+// ReloadTime returns the time the config was successfully loaded at
+// for the last time, either by Parse() or by a subsequent reload.
+//
+// It returns zero time if Parse() wasn't called yet.
+func ReloadTime() time.Time {
+	reloadedAtMu.Lock()
+	defer reloadedAtMu.Unlock()
+	return reloadedAt
+}
+
+func setReloadTime(t time.Time) {
+	reloadedAtMu.Lock()
+	reloadedAt = t
+	reloadedAtMu.Unlock()
+}
+
+// incGeneration increments Generation and notifies channels
+// returned by GenerationChanges().
+func incGeneration() {
+	Generation++
+	generationChansMu.Lock()
+	defer generationChansMu.Unlock()
+	for _, ch := range generationChans {
+		select {
+		case ch <- Generation:
+		default:
+			// Replace the pending notification with the new Generation.
+			select {
+			case <-ch:
+			default:
 			}
+			ch <- Generation
 		}
 	}
 }
 
-func issueAllFlagChangeCallbacks() {
-	for _, fs := range flagChangeCallbacks {
-		for _, f := range fs {
-			f()
-		}
-	}
+// GenerationChanges returns a channel receiving the new Generation value
+// after each flags' modification.
+//
+// The channel is buffered, so notifications never block iniflags.
+// Only the latest Generation is kept if the receiver falls behind.
+func GenerationChanges() <-chan int {
+	ch := make(chan int, 1)
+	generationChansMu.Lock()
+	generationChans = append(generationChans, ch)
+	generationChansMu.Unlock()
+	return ch
 }
 
-func sighupHandler(ch <-chan os.Signal) {
-	for _ = range ch {
-		updateConfig()
+// recordFlagGenerations records the current Generation as the generation
+// the given flags were last changed at.
+func recordFlagGenerations(changedFlags map[string]string) {
+	flagGenerationsMu.Lock()
+	defer flagGenerationsMu.Unlock()
+	for flagName := range changedFlags {
+		flagGenerations[flagName] = Generation
 	}
 }
 
-func parseConfigFlags() (oldFlagValues map[string]string, ok bool) {
-	configPath := *config
-	if !strings.HasPrefix(configPath, "./") {
-		if configPath, ok = combinePath(os.Args[0], *config); !ok {
-			return nil, false
+// ChangedSince returns sorted names of flags changed after the given
+// Generation.
+//
+// Flags set via command-line or config file during Parse() are considered
+// changed at the Generation obtained during Parse(), so ChangedSince(0)
+// returns all the flags set to non-default values.
+//
+// This allows components to remember the Generation they last processed
+// and to reconfigure only the flags changed since then.
+func ChangedSince(gen int) []string {
+	flagGenerationsMu.Lock()
+	defer flagGenerationsMu.Unlock()
+	var names []string
+	for flagName, g := range flagGenerations {
+		if g > gen {
+			names = append(names, flagName)
 		}
 	}
-	if configPath == "" {
-		return nil, true
-	}
-	parsedArgs, ok := getArgsFromConfig(configPath)
-	if !ok {
-		return nil, false
-	}
-	missingFlags := getMissingFlags()
+	sort.Strings(names)
+	return names
+}
 
-	ok = true
-	oldFlagValues = make(map[string]string)
-	for _, arg := range parsedArgs {
+// ReloadTrigger describes the reason flag values have been (re)loaded.
+type ReloadTrigger int
 
-		f := flag.Lookup(arg.Key)
-		if f == nil {
-			// Check if the key is a shorthand
-			if fullName, isShorthand := flagShorthands[arg.Key]; isShorthand {
-				f = flag.Lookup(fullName)
-				arg.Key = fullName // Update the key to use the full name
-			}
-		}
-		if f == nil {
-			logger.Printf("iniflags: unknown flag name=[%s] found at line [%d] of file [%s]", arg.Key, arg.LineNum, arg.FilePath)
-			if !*allowUnknownFlags {
-				ok = false
-			}
-			continue
-		}
+const (
+	// TriggerInitialParse is used for the initial config load in Parse().
+	TriggerInitialParse ReloadTrigger = iota
 
-		if _, found := missingFlags[f.Name]; found {
-			oldValue := f.Value.String()
-			if oldValue == arg.Value {
-				continue
-			}
-			if err := f.Value.Set(arg.Value); err != nil {
-				logger.Printf("iniflags: error when parsing flag [%s] value [%s] at line [%d] of file [%s]: [%s]", arg.Key, arg.Value, arg.LineNum, arg.FilePath, err)
-				ok = false
-				continue
-			}
-			if oldValue != f.Value.String() {
-				oldFlagValues[arg.Key] = oldValue
-			}
-		}
-	}
+	// TriggerSIGHUP is used for config reloads on SIGHUP signal.
+	TriggerSIGHUP
 
-	if !ok {
-		// restore old flag values
-		for k, v := range oldFlagValues {
-			flag.Set(k, v)
-		}
-		oldFlagValues = nil
+	// TriggerTimer is used for periodic config reloads enabled
+	// with -configUpdateInterval.
+	TriggerTimer
+
+	// TriggerManual is used for config reloads requested via TriggerReload()
+	// and for config files loaded via ReloadFile() or ParseConfigFileOnly().
+	TriggerManual
+
+	// TriggerFlagSet is used for flags set by the application
+	// via SetFlagValueWithoutCallback() followed by FirePendingCallbacks()
+	// or via SetFlagFromFile().
+	TriggerFlagSet
+
+	// TriggerFileChange is used for flags updated because files referenced
+	// via @file values have been changed.
+	TriggerFileChange
+)
+
+// String returns human-readable name for the trigger.
+func (t ReloadTrigger) String() string {
+	switch t {
+	case TriggerInitialParse:
+		return "initial parse"
+	case TriggerSIGHUP:
+		return "SIGHUP"
+	case TriggerTimer:
+		return "timer"
+	case TriggerManual:
+		return "manual"
+	case TriggerFlagSet:
+		return "flag set"
+	case TriggerFileChange:
+		return "file change"
+	default:
+		return fmt.Sprintf("ReloadTrigger(%d)", int(t))
 	}
+}
 
-	return oldFlagValues, ok
+// ConfigReloadCallback is called after the config is successfully (re)loaded.
+//
+// The callback may be registered via OnConfigReload().
+type ConfigReloadCallback func(trigger ReloadTrigger)
+
+// OnConfigReload registers the callback, which is called after the config
+// is successfully loaded during Parse() and after every successful config
+// reload, with the trigger for the reload.
+//
+// Unlike callbacks registered via OnFlagChange(), the callback is called
+// even if the reload didn't modify any flags.
+func OnConfigReload(callback ConfigReloadCallback) {
+	configReloadCallbacks = append(configReloadCallbacks, callback)
 }
 
-func checkImportRecursion(configPath string) bool {
-	for _, path := range importStack {
-		if path == configPath {
-			logger.Printf("iniflags: import recursion found for [%s]: %v", configPath, importStack)
-			return false
-		}
+// OnReady registers the callback, which is called once after Parse()
+// successfully loads the config.
+//
+// The callback is called after all the constraints and validators passed
+// and after callbacks registered via OnFlagChange() and OnConfigReload()
+// are called, so it signals the config is fully loaded and valid.
+// It isn't called if Parse() fails.
+//
+// Must be called before Parse().
+func OnReady(callback func()) {
+	if parsed {
+		logger.Panicf("iniflags: OnReady() must be called before Parse()")
 	}
-	return true
+	readyCallbacks = append(readyCallbacks, callback)
 }
 
-type flagArg struct {
-	Key      string
-	Value    string
-	FilePath string
-	LineNum  int
-	Comment  string
+// OnConfigReloadFailure registers the handler, which is called when
+// a config reload fails, e.g. because of invalid syntax or invalid values.
+//
+// The handler is called with the error and the current Generation, which
+// isn't changed by the failed reload, since the previous flag values
+// are kept. This allows alerting on broken configs in production.
+// The error wraps *ParseError, which may be obtained via errors.As().
+// The handler isn't called if Parse() fails. It is called without holding
+// the reload lock, so it may trigger config reloads or set flags.
+func OnConfigReloadFailure(handler func(err error, generation int)) {
+	reloadFailureHandlers = append(reloadFailureHandlers, handler)
 }
 
-func stripBOM(s string) string {
-	if len(s) < 3 {
-		return s
+func issueReloadFailureHandlers(err error) {
+	for _, handler := range reloadFailureHandlers {
+		handler(err, Generation)
 	}
-	bom := s[:3]
-	if bom == "\ufeff" || bom == "\ufffe" {
-		return s[3:]
+}
+
+func issueConfigReloadCallbacks(trigger ReloadTrigger) {
+	for _, f := range configReloadCallbacks {
+		f(trigger)
 	}
-	return s
 }
 
-func ReadIniFile(iniFilePath string) (args []flagArg, ok bool) {
-	return getArgsFromConfig(iniFilePath)
+// TriggerReload re-reads the config file.
+//
+// It must be called after Parse().
+func TriggerReload() {
+	if !parsed {
+		logger.Panicf("iniflags: TriggerReload() must be called after Parse()")
+	}
+	updateConfig(TriggerManual)
 }
 
-func getArgsFromConfig(configPath string) (args []flagArg, ok bool) {
-	if !checkImportRecursion(configPath) {
-		return nil, false
+// ParseConfigFileOnly loads an additional config file after Parse()
+// without re-parsing command line.
+//
+// Values from the file are applied to flags not set via command line,
+// Generation is incremented and callbacks registered via OnFlagChange()
+// are called for the changed flags. See also ReloadFile().
+//
+// It must be called after Parse().
+func ParseConfigFileOnly(configPath string) error {
+	if !parsed {
+		logger.Panicf("iniflags: ParseConfigFileOnly() must be called after Parse()")
 	}
-	importStack = append(importStack, configPath)
-	defer func() {
-		importStack = importStack[:len(importStack)-1]
-	}()
+	_, err := ReloadFile(configPath)
+	return err
+}
 
-	file, err := openConfigFile(configPath)
-	if err != nil {
-		return nil, *allowMissingConfig
+// SetFlagValueWithoutCallback sets the flag with the given name
+// to the given value without calling callbacks registered via OnFlagChange().
+//
+// Callbacks for flags changed this way are called on the subsequent
+// FirePendingCallbacks() call. This allows updating related flags
+// in bulk, so callbacks don't observe half-updated state.
+//
+// Unlike flag.Set(), the flag isn't marked as set via command line,
+// so its value may be overridden on the next config reload.
+func SetFlagValueWithoutCallback(name, value string) error {
+	if fullName, ok := flagShorthands[name]; ok {
+		name = fullName
+	}
+	f := flag.Lookup(name)
+	if f == nil {
+		return fmt.Errorf("iniflags: cannot set value for unknown flag [%s]", name)
 	}
-	defer file.Close()
-	r := bufio.NewReader(file)
 
-	var lineNum int
-	var comment = ""
-	var multilineFA flagArg
-	for {
-		lineNum++
-		line, err := r.ReadString('\n')
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
 
-		if err != nil && line == "" {
-			if err == io.EOF {
-				if len(multilineFA.Key) > 0 {
-					// flush the last multiline arg
-					args = append(args, multilineFA)
-				}
-				break
-			}
-			logger.Printf("iniflags: error when reading file [%s] at line %d: [%s]", configPath, lineNum, err)
-			return nil, false
-		}
+	oldValue := f.Value.String()
+	if err := setFlagValue(f, value); err != nil {
+		restoreFlagValue(f, oldValue)
+		return fmt.Errorf("iniflags: cannot set flag [%s] to [%s]: [%s]", name, redactValue(name, value), redactError(name, err, value))
+	}
+	if traceHook != nil {
+		traceHook("set", name, redactValue(name, f.Value.String()))
+	}
+	if _, ok := pendingCallbacks[name]; !ok && oldValue != f.Value.String() {
+		pendingCallbacks[name] = oldValue
+	}
+	return nil
+}
 
-		// check if line is encoded in UTF-8
-		if !utf8.ValidString(line) {
-			logger.Printf("iniflags: invalid UTF-8 encoding at line %d of file [%s]", lineNum, configPath)
-			return nil, false
-		}
+// FirePendingCallbacks calls callbacks registered via OnFlagChange()
+// for flags changed via SetFlagValueWithoutCallback() since the previous
+// FirePendingCallbacks() call.
+//
+// Parse() calls callbacks for all the flags, so there is no need to call
+// FirePendingCallbacks() for values set before Parse().
+func FirePendingCallbacks() {
+	reloadMu.Lock()
+	if !parsed || len(pendingCallbacks) == 0 {
+		pendingCallbacks = make(map[string]string)
+		reloadMu.Unlock()
+		return
+	}
+	oldFlagValues := pendingCallbacks
+	pendingCallbacks = make(map[string]string)
+	changes := newFlagChanges(TriggerFlagSet, oldFlagValues)
+	reloadMu.Unlock()
 
-		if lineNum == 1 {
-			line = stripBOM(line)
-		}
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "#import ") {
-			importPath, _, ok := unquoteValue(line[7:], lineNum, configPath)
-			if !ok {
-				return nil, false
-			}
-			if importPath, ok = combinePath(configPath, importPath); !ok {
+	changes.issue()
+}
+
+// ReloadFile reads the config file at the given path and applies its
+// values to flags not set via command line.
+//
+// Unlike TriggerReload(), it doesn't re-read the config set via -config,
+// so flags missing in the file retain their current values. This is useful
+// for reloading small overlay files such as feature flags.
+//
+// It returns sorted names of the changed flags. Callbacks registered
+// via OnFlagChange() are called for these flags.
+//
+// It must be called after Parse().
+func ReloadFile(configPath string) (changed []string, err error) {
+	if !parsed {
+		logger.Panicf("iniflags: ReloadFile() must be called after Parse()")
+	}
+	reloadMu.Lock()
+	changed, changes, err := reloadFile(configPath)
+	reloadMu.Unlock()
+
+	if changes != nil {
+		changes.issue()
+	}
+	return changed, err
+}
+
+// reloadFile applies values from the config file at the given path
+// and returns the changes to notify callbacks about.
+//
+// reloadMu must be held by the caller.
+func reloadFile(configPath string) (changed []string, changes *flagChanges, err error) {
+	p := newConfigParser()
+	p.allowMissing = false
+	args, err := p.parseFile(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	reloading = true
+	oldFlagValues, newDynamicValues, newConfigFlags, ok := applyFlagArgs(args)
+	reloading = false
+	if !ok {
+		return nil, nil, fmt.Errorf("iniflags: cannot apply values from config file [%s]", configPath)
+	}
+	mergeDynamicValues(newDynamicValues)
+	for flagName := range newConfigFlags {
+		configFlags[flagName] = true
+	}
+	if len(oldFlagValues) == 0 {
+		return nil, nil, nil
+	}
+	for flagName := range oldFlagValues {
+		changed = append(changed, flagName)
+	}
+	sort.Strings(changed)
+	logger.Printf("iniflags: read updated config file [%s]. Modified flags are: %v", configPath, changed)
+	return changed, newFlagChanges(TriggerManual, oldFlagValues), nil
+}
+
+// SetReloadable sets whether the flag with the given name may be changed
+// on config reload.
+//
+// All the flags are reloadable by default. New values for non-reloadable
+// flags are ignored on config reload with a log message that the app must
+// be restarted to apply them.
+func SetReloadable(name string, ok bool) {
+	nonReloadableFlagsMu.Lock()
+	defer nonReloadableFlagsMu.Unlock()
+	if ok {
+		delete(nonReloadableFlags, name)
+	} else {
+		nonReloadableFlags[name] = true
+	}
+}
+
+// IsReloadable returns true if the flag with the given name may be changed
+// on config reload.
+func IsReloadable(name string) bool {
+	nonReloadableFlagsMu.Lock()
+	excluded := nonReloadableFlags[name]
+	excludeFunc := reloadExcludeFunc
+	nonReloadableFlagsMu.Unlock()
+	if excluded {
+		return false
+	}
+	return excludeFunc == nil || !excludeFunc(name)
+}
+
+// SetReloadExcludeFunc sets the function for excluding flags from config
+// reload.
+//
+// Flags for which excludeFunc returns true are treated as non-reloadable
+// in addition to flags marked via SetReloadable(name, false). This allows
+// freezing whole classes of flags, e.g. all the flags with "tls." prefix:
+//
+//	iniflags.SetReloadExcludeFunc(func(name string) bool {
+//		return strings.HasPrefix(name, "tls.")
+//	})
+//
+// Pass nil for removing the function.
+func SetReloadExcludeFunc(excludeFunc func(name string) bool) {
+	nonReloadableFlagsMu.Lock()
+	reloadExcludeFunc = excludeFunc
+	nonReloadableFlagsMu.Unlock()
+}
+
+// ReloadableFlags returns sorted names of flags, which may be changed
+// on config reload.
+func ReloadableFlags() []string {
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if IsReloadable(f.Name) {
+			names = append(names, f.Name)
+		}
+	})
+	return names
+}
+
+// SetCallbackOnConfirm enables calling OnFlagChange() callbacks for the flag
+// with the given name on config reloads, which set the flag to its current
+// value.
+//
+// By default callbacks are called only if the flag value is changed.
+// Enabled confirmation callbacks allow verifying the flag is still present
+// in the reloaded config. Callbacks registered via OnFlagChangeEvent()
+// obtain events with Confirmed set for such reloads. Flags set via command
+// line, environment variables or SetOverrides() and non-reloadable flags
+// with ignored config values aren't confirmed by config reloads.
+func SetCallbackOnConfirm(flagName string, enabled bool) {
+	if fullName, ok := flagShorthands[flagName]; ok {
+		flagName = fullName
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	if enabled {
+		confirmCallbackFlags[flagName] = true
+	} else {
+		delete(confirmCallbackFlags, flagName)
+	}
+}
+
+// SetReloadMutex sets the mutex, which is locked while iniflags modifies
+// flag values on config reload.
+//
+// Application code reading flag values from other goroutines may call
+// mu.RLock() before reading them in order to avoid observing the config
+// applied partially. Callbacks registered via OnFlagChange() are called
+// after the mutex is unlocked, so they may lock it as well.
+//
+// Must be called before Parse().
+func SetReloadMutex(mu *sync.RWMutex) {
+	if parsed {
+		logger.Panicf("iniflags: SetReloadMutex() must be called before Parse()")
+	}
+	flagsMutex = mu
+}
+
+func lockFlagsMutex() {
+	if flagsMutex != nil {
+		flagsMutex.Lock()
+	}
+}
+
+func unlockFlagsMutex() {
+	if flagsMutex != nil {
+		flagsMutex.Unlock()
+	}
+}
+
+// FlagChangeCallback is called when the given flag is changed.
+//
+// The callback may be registered for any flag via OnFlagChange().
+type FlagChangeCallback func()
+
+// OnFlagChange registers the callback, which is called after the given flag
+// value is initialized and/or changed.
+//
+// Flag values are initialized during iniflags.Parse() call.
+// Flag value can be changed on config re-read after obtaining SIGHUP signal
+// or if periodic config re-read is enabled with -configUpdateInterval flag.
+//
+// The flagName may be a shorthand registered via RegisterShorthand().
+// The callback is registered for the full flag name in this case.
+//
+// Callbacks are called after the reload completes, so they may call
+// TriggerReload(), ReloadFile() or set flags. Use OnFlagChangeEvent()
+// for obtaining the trigger for the change.
+//
+// Note that flags set via command-line cannot be overriden via config file modifications.
+func OnFlagChange(flagName string, callback FlagChangeCallback) {
+	if fullName, ok := flagShorthands[flagName]; ok {
+		flagName = fullName
+	}
+	if parsed {
+		verifyFlagChangeFlagName(flagName)
+	}
+	flagChangeCallbacks[flagName] = append(flagChangeCallbacks[flagName], callback)
+}
+
+func verifyFlagChangeFlagName(flagName string) {
+	if flag.Lookup(flagName) == nil {
+		logger.Fatalf("iniflags: cannot register FlagChangeCallback for non-existing flag [%s]", flagName)
+	}
+}
+
+// FlagChangeEvent describes the change of a flag value.
+//
+// Values of flags marked via MarkSecret() are redacted.
+type FlagChangeEvent struct {
+	// Name is the flag name.
+	Name string
+
+	// OldValue is the flag value before the change.
+	OldValue string
+
+	// NewValue is the flag value after the change.
+	NewValue string
+
+	// Trigger is the reason for the change.
+	Trigger ReloadTrigger
+
+	// Generation is the Generation the flag has been changed at.
+	Generation int
+
+	// Confirmed is true if the reloaded config contains the current
+	// flag value, so the value hasn't been changed. Such events are sent
+	// only for flags enabled via SetCallbackOnConfirm().
+	Confirmed bool
+}
+
+// FlagChangeEventCallback is called with the event describing
+// the change of the given flag.
+//
+// The callback may be registered for any flag via OnFlagChangeEvent().
+type FlagChangeEventCallback func(event FlagChangeEvent)
+
+// OnFlagChangeEvent registers the callback, which is called after the given
+// flag value is initialized and/or changed, like callbacks registered
+// via OnFlagChange().
+//
+// The callback obtains the old and the new value and the trigger
+// for the change, so audit logs may distinguish changes made by operators
+// from automatic ones.
+func OnFlagChangeEvent(flagName string, callback FlagChangeEventCallback) {
+	if fullName, ok := flagShorthands[flagName]; ok {
+		flagName = fullName
+	}
+	if parsed {
+		verifyFlagChangeFlagName(flagName)
+	}
+	flagEventCallbacks[flagName] = append(flagEventCallbacks[flagName], callback)
+}
+
+func issueFlagChangeCallbacks(event FlagChangeEvent) {
+	for _, f := range flagChangeCallbacks[event.Name] {
+		f()
+	}
+	for _, f := range flagEventCallbacks[event.Name] {
+		f(event)
+	}
+}
+
+// confirmedFlags returns events sorted by flag names for flags enabled
+// via SetCallbackOnConfirm(), which were found in the reloaded config
+// with unchanged values.
+//
+// Non-reloadable flags with ignored config values aren't confirmed,
+// since the config contains another value.
+func confirmedFlags(trigger ReloadTrigger, oldFlagValues map[string]string) []FlagChangeEvent {
+	if len(confirmCallbackFlags) == 0 {
+		return nil
+	}
+	missingFlags := getMissingFlags()
+	var names []string
+	for flagName := range confirmCallbackFlags {
+		if _, changed := oldFlagValues[flagName]; changed || !configFlags[flagName] || ignoredConfigFlags[flagName] {
+			continue
+		}
+		if _, fromConfig := missingFlags[flagName]; !fromConfig {
+			continue
+		}
+		names = append(names, flagName)
+	}
+	sort.Strings(names)
+	events := make([]FlagChangeEvent, 0, len(names))
+	for _, name := range names {
+		value := redactValue(name, flag.Lookup(name).Value.String())
+		events = append(events, FlagChangeEvent{
+			Name:       name,
+			OldValue:   value,
+			NewValue:   value,
+			Trigger:    trigger,
+			Generation: Generation,
+			Confirmed:  true,
+		})
+	}
+	return events
+}
+
+// issueAllFlagChangeCallbacks calls all the callbacks after the initial
+// config load. changedFlags contains old values for flags changed
+// during Parse().
+func issueAllFlagChangeCallbacks(changedFlags map[string]string) {
+	for _, fs := range flagChangeCallbacks {
+		for _, f := range fs {
+			f()
+		}
+	}
+	for flagName, fs := range flagEventCallbacks {
+		value := redactValue(flagName, flag.Lookup(flagName).Value.String())
+		oldValue := value
+		if v, ok := changedFlags[flagName]; ok {
+			oldValue = redactValue(flagName, v)
+		}
+		event := FlagChangeEvent{
+			Name:       flagName,
+			OldValue:   oldValue,
+			NewValue:   value,
+			Trigger:    TriggerInitialParse,
+			Generation: Generation,
+		}
+		for _, f := range fs {
+			f(event)
+		}
+	}
+}
+
+func sighupHandler(ch <-chan os.Signal) {
+	for _ = range ch {
+		updateConfig(TriggerSIGHUP)
+	}
+}
+
+func parseConfigFlags() (oldFlagValues map[string]string, ok bool) {
+	var configPaths []string
+	var parsedArgs []FlagArg
+	paths := resolveConfigPaths(*config, isSetOnCommandLine("config"), os.Getenv(configEnvVar), addedConfigFiles)
+	for i, p := range paths {
+		if !strings.HasPrefix(p, "./") {
+			if paths[i], ok = combinePath(getProgramPath(), p); !ok {
 				return nil, false
 			}
-			importArgs, ok := getArgsFromConfig(importPath)
+		}
+	}
+	if len(paths) > 0 && defaultConfigContent != "" && allConfigFilesMissing(paths) {
+		logger.Printf("iniflags: config files %v not found; using the default config content", paths)
+		paths = nil
+	}
+	switch {
+	case len(paths) > 0:
+		for _, p := range paths {
+			args, ok := getArgsFromConfig(p)
 			if !ok {
 				return nil, false
 			}
-			args = append(args, importArgs...)
-			continue
+			parsedArgs = append(parsedArgs, args...)
+			configPaths = append(configPaths, p)
 		}
-		if line == "" || line[0] == '[' {
-			comment = ""
-			continue
+	case len(configURLs) > 0:
+		var configURL string
+		if parsedArgs, configURL, ok = getArgsFromConfigURLs(configURLs); !ok {
+			return nil, false
 		}
-		if line[0] == '#' || line[0] == ';' {
-			//save the comment and move to the next line
-			comment = line[1:]
-			continue
+		if configURL != "" {
+			configPaths = []string{configURL}
 		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			logger.Printf("iniflags: cannot split [%s] at line %d into key and value in config file [%s]", line, lineNum, configPath)
+	case defaultConfigContent != "":
+		args, err := newConfigParser().parseReader(defaultConfigName, strings.NewReader(defaultConfigContent))
+		if err != nil {
+			reportConfigError(ParseError{FilePath: defaultConfigName, Err: err}, err.Error())
 			return nil, false
 		}
-		key := strings.TrimSpace(parts[0])
-
-		value, cmt, ok := unquoteValue(parts[1], lineNum, configPath)
-		if !ok {
+		parsedArgs = args
+	default:
+		return nil, true
+	}
+	if requireAllFlags {
+		if missing := getFlagsMissingInConfig(parsedArgs); len(missing) > 0 {
+			configPath := strings.Join(configPaths, string(os.PathListSeparator))
+			reportConfigError(ParseError{FilePath: configPath, Err: fmt.Errorf("missing flags %v", missing)},
+				fmt.Sprintf("iniflags: the following flags are missing in config [%s]: %v", configPath, missing))
 			return nil, false
 		}
-		if comment == "" {
-			comment = cmt
-		}
-
-		fa := flagArg{
-			Key:      key,
-			Value:    value,
-			FilePath: configPath,
-			LineNum:  lineNum,
-			Comment:  comment,
-		}
+	}
+	oldFlagValues, newDynamicValues, newConfigFlags, ok := applyFlagArgs(parsedArgs)
+	if ok {
+		loadedConfigPaths = configPaths
+		setDynamicValues(newDynamicValues)
+		configFlags = newConfigFlags
+		pruneFileRefs(newConfigFlags)
+	}
+	return oldFlagValues, ok
+}
 
-		comment = ""
-		if !strings.HasSuffix(key, "}") {
-			if len(multilineFA.Key) > 0 {
-				// flush the last multiline arg
-				args = append(args, multilineFA)
-				multilineFA = flagArg{}
+// allConfigFilesMissing returns true if none of the local config files
+// at the given paths exist.
+//
+// Remote configs are assumed to exist, since they may be temporarily
+// unavailable.
+func allConfigFilesMissing(paths []string) bool {
+	for _, p := range paths {
+		if isFileURL(p) {
+			filePath, err := fileURLPath(p)
+			if err != nil {
+				return false
 			}
-
-			args = append(args, fa)
-			continue
+			p = filePath
+		} else if isURL(p) {
+			return false
 		}
-
-		// multiline arg
-		n := strings.LastIndex(key, "{")
-		if n < 0 {
-			log.Printf("iniflags: cannot find '{' in the multiline key [%s] at line %d, file [%s]", key, lineNum, configPath)
-			return nil, false
+		if configPathMapper != nil {
+			p = configPathMapper(p)
 		}
-		switch multilineFA.Key {
-		case "":
-			// the first line for multiline arg
-			multilineFA = fa
-			multilineFA.Key = key[:n]
-		case key[:n]:
-			// the subsequent line for multiline arg
-			delimiter := key[n+1 : len(key)-1]
-			multilineFA.Value += delimiter
-			multilineFA.Value += value
-		default:
-			// new multiline arg
-			args = append(args, multilineFA)
-			multilineFA = fa
-			multilineFA.Key = key[:n]
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			return false
 		}
 	}
+	return true
+}
 
-	return args, true
+// configEnvVar is the environment variable with config paths, which is used
+// if -config isn't passed via command line.
+const configEnvVar = "INIFLAGS_CONFIG"
+
+// resolveConfigPaths returns config paths to load according to the priority
+// described in the package docs.
+func resolveConfigPaths(configValue string, setOnCommandLine bool, envValue string, added []string) []string {
+	switch {
+	case setOnCommandLine:
+		return splitConfigPaths(configValue)
+	case envValue != "":
+		return splitConfigPaths(envValue)
+	case len(added) > 0:
+		return added
+	default:
+		return splitConfigPaths(configValue)
+	}
 }
 
-func openConfigFile(path string) (io.ReadCloser, error) {
-	if isHTTP(path) {
-		var resp *http.Response
-		var err error
-		// check path if it is secure
-		if isSecure(path) {
-			// It's a https path, so no need to check if unsecure is set
-			resp, err = http.Get(path)
-		} else {
-			if !*unsecure {
-				logger.Printf("iniflags: cannot load config file at [%s]: unsecure communication is not allowed", path)
-				return nil, fmt.Errorf("unsecure communication is not allowed")
-			} else {
-				resp, err = http.Get(path)
-				// warn if unsecure is set and the path is not secure
-				logger.Printf("iniflags: unsecure communication with the server at [%s]", path)
+// isSetOnCommandLine returns true if the flag with the given name
+// has been set via command line.
+func isSetOnCommandLine(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// applyFlagArgs applies args read from config to flags not set
+// via command line.
+//
+// It returns old values for the modified flags, values for unknown keys
+// if -allowUnknownFlags is set and names of flags found in args.
+// All the modifications are rolled back on error.
+func applyFlagArgs(args []FlagArg) (oldFlagValues map[string]string, newDynamicValues map[string]dynamicValue, newConfigFlags map[string]bool, ok bool) {
+	lockFlagsMutex()
+	defer unlockFlagsMutex()
+
+	missingFlags := getMissingFlags()
+	oldFileRefs := snapshotFileRefs()
+	ignoredConfigFlags = make(map[string]bool)
+
+	oldFlagValues = make(map[string]string)
+	newDynamicValues = make(map[string]dynamicValue)
+	newConfigFlags = make(map[string]bool)
+	errs := applyArgs(flag.CommandLine, args, flagShorthands, func(f *flag.Flag, arg FlagArg) error {
+		if f == nil {
+			if *allowUnknownFlags {
+				if err := checkDynamicValueType(arg.Value, arg.Type); err != nil {
+					return err
+				}
+				newDynamicValues[arg.Key] = dynamicValue{value: arg.Value, typ: arg.Type}
+				logger.Printf("iniflags: unknown flag name=[%s] found at line [%d] of file [%s]", arg.Key, arg.LineNum, arg.FilePath)
+				return nil
 			}
+			return fmt.Errorf("unknown flag")
 		}
 
+		newConfigFlags[f.Name] = true
+		_, found := missingFlags[f.Name]
+		cliItems, merge := cliSliceItems[f.Name]
+		if !found && !merge {
+			return nil
+		}
+		oldValue := f.Value.String()
+		value, err := resolveFlagValue(f, arg.Value, arg.FilePath)
+		if err == nil && merge {
+			value, err = mergeSliceItems(value, cliItems)
+		}
+		if err == nil {
+			if oldValue == value {
+				return nil
+			}
+			if reloading && !IsReloadable(f.Name) {
+				logger.Printf("iniflags: ignoring new value [%s] for non-reloadable flag [%s] at line [%d] of file [%s]; restart the app to apply it", redactValue(arg.Key, arg.Value), arg.Key, arg.LineNum, arg.FilePath)
+				ignoredConfigFlags[f.Name] = true
+				return nil
+			}
+			if err = setFlagValue(f, value); err != nil {
+				restoreFlagValue(f, oldValue)
+			}
+		}
 		if err != nil {
-			logger.Printf("iniflags: cannot load config file at [%s]: [%s]\n", path, err)
-			return nil, err
+			err = redactError(arg.Key, err, arg.Value, value)
+			if skipInvalidValues {
+				logger.Printf("iniflags: skipping invalid value [%s] for flag [%s] at line [%d] of file [%s]: [%s]", redactValue(arg.Key, arg.Value), arg.Key, arg.LineNum, arg.FilePath, err)
+				return nil
+			}
+			return err
 		}
-		if resp.StatusCode != http.StatusOK {
-			logger.Printf("iniflags: unexpected http status code when obtaining config file [%s]: %d. Expected %d", path, resp.StatusCode, http.StatusOK)
-			return nil, err
+		if oldValue != f.Value.String() {
+			oldFlagValues[arg.Key] = oldValue
 		}
-		return resp.Body, nil
+		if traceHook != nil {
+			source := "config"
+			if reloading {
+				source = "reload"
+			}
+			traceHook(source, arg.Key, redactValue(arg.Key, f.Value.String()))
+		}
+		return nil
+	})
+	for _, pe := range errs {
+		reportConfigError(pe, pe.Error())
 	}
+	ok = len(errs) == 0
 
-	file, err := os.Open(path)
-	if err != nil {
-		if !(*allowMissingConfig) {
-			logger.Printf("iniflags: cannot open config file at [%s]: [%s]", path, err)
+	if !ok {
+		// restore old flag values.
+		// Do not use flag.Set(), since it marks the flag as set via command line.
+		for k, v := range oldFlagValues {
+			setFlagValue(flag.Lookup(k), v)
+			if traceHook != nil {
+				traceHook("rollback", k, redactValue(k, v))
+			}
 		}
-		return nil, err
+		restoreFileRefs(oldFileRefs)
+		return nil, nil, nil, false
+	}
+	return oldFlagValues, newDynamicValues, newConfigFlags, true
+}
+
+// setFlagValue sets the value read from config to the flag f.
+//
+// Unlike flag.Set(), it doesn't mark the flag as set via command line.
+func setFlagValue(f *flag.Flag, value string) error {
+	if err := checkFrozen(f); err != nil {
+		return err
+	}
+	var err error
+	if sv, ok := f.Value.(sliceValue); ok {
+		var items []string
+		if items, err = parseList(value); err != nil {
+			return err
+		}
+		err = sv.Replace(items)
+	} else {
+		err = f.Value.Set(value)
+	}
+	if err != nil {
+		return err
+	}
+	return validateFlagValue(f)
+}
+
+// restoreFlagValue restores the value of the flag f to oldValue
+// after a failed setFlagValue call.
+//
+// Set may modify the value on error. The value is restored only if it has
+// been modified, since Set of accumulating values such as custom list
+// flags appends oldValue instead of replacing the value with it.
+func restoreFlagValue(f *flag.Flag, oldValue string) {
+	if f.Value.String() == oldValue {
+		return
+	}
+	setFlagValue(f, oldValue)
+}
+
+// Validator may be implemented by flag values in order to verify
+// the value after it is set.
+//
+// Validate is called after every Set call made by iniflags, including
+// values read from config files, environment variables and SetOverrides().
+// Values set via command line are validated in Parse(). The error returned
+// by Validate is treated the same way as the error returned by Set.
+// This allows complex flag types to verify constraints spanning
+// multiple fields.
+type Validator interface {
+	Validate() error
+}
+
+func validateFlagValue(f *flag.Flag) error {
+	if v, ok := f.Value.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// trimFlagValue applies cutsets registered via TrimAllFlags()
+// and TrimFlagValue() to the value for the flag f.
+func trimFlagValue(f *flag.Flag, value string) string {
+	if len(allFlagsTrimCutsets) > 0 {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			if _, isString := getter.Get().(string); isString {
+				for _, cutset := range allFlagsTrimCutsets {
+					value = strings.Trim(value, cutset)
+				}
+			}
+		}
+	}
+	for _, cutset := range flagTrimCutsets[f.Name] {
+		value = strings.Trim(value, cutset)
+	}
+	return value
+}
+
+// splitConfigPaths splits the list of config paths separated
+// by os.PathListSeparator.
+//
+// Since the separator is ':' on Unix, http, https and file urls such as
+// "http://host:8080/config.ini" are split out before splitting the rest
+// of the list, so colons in url schemes and ports aren't treated
+// as separators. Colons in url paths aren't supported.
+func splitConfigPaths(s string) []string {
+	var paths []string
+	for s != "" {
+		n := configURLLen(s)
+		if n == 0 {
+			if n = strings.IndexRune(s, os.PathListSeparator); n < 0 {
+				n = len(s)
+			}
+		}
+		if n > 0 {
+			paths = append(paths, s[:n])
+		}
+		s = s[n:]
+		if s != "" {
+			// skip the separator
+			s = s[1:]
+		}
+	}
+	return paths
+}
+
+// configURLLen returns the length of the url at the start of the config
+// path list s or 0 if s doesn't start with a url.
+//
+// The url ends at the path list separator following the url path.
+// The separator may occur in the url host only before the port.
+func configURLLen(s string) int {
+	if !isURL(s) {
+		return 0
+	}
+	sep := byte(os.PathListSeparator)
+	n := strings.Index(s, "://") + len("://")
+	if n < len(s) && s[n] == '[' {
+		// skip IPv6 address
+		if m := strings.IndexByte(s[n:], ']'); m >= 0 {
+			n += m + 1
+		}
+	}
+	for ; n < len(s) && s[n] != '/'; n++ {
+		if s[n] == sep && !isURLPort(s[n+1:]) {
+			return n
+		}
+	}
+	if m := strings.IndexByte(s[n:], sep); m >= 0 {
+		return n + m
+	}
+	return len(s)
+}
+
+// isURLPort returns true if s starts with url port followed by the url path,
+// the path list separator or the end of s.
+func isURLPort(s string) bool {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n > 0 && (n == len(s) || s[n] == '/' || s[n] == byte(os.PathListSeparator))
+}
+
+// FlagArg is a flag value read from a config file.
+type FlagArg struct {
+	Key      string
+	Value    string
+	Type     string // Optional value type set via "key:type = value" syntax
+	FilePath string
+	LineNum  int
+	Comment  string
+}
+
+func stripBOM(s string) string {
+	if len(s) < 3 {
+		return s
+	}
+	bom := s[:3]
+	if bom == "\ufeff" || bom == "\ufffe" {
+		return s[3:]
+	}
+	return s
+}
+
+// ReadIniFile reads flag values from the given ini file without applying them.
+//
+// Relative paths such as "./config.ini" are resolved against the current
+// working directory, while relative #import paths are resolved against
+// the directory of the importing file.
+//
+// The file is parsed according to the global iniflags settings such as
+// -allowMissingConfig. Use ReadIniFileWithOptions() for parsing
+// independently of these settings.
+func ReadIniFile(iniFilePath string) (args []FlagArg, ok bool) {
+	return getArgsFromConfig(iniFilePath)
+}
+
+// ReadOptions contains options for ReadIniFileWithOptions().
+type ReadOptions struct {
+	// AllowMissing allows missing config files, including imported ones.
+	AllowMissing bool
+
+	// MaxDepth limits the depth of #import directives.
+	// Zero means no limit.
+	MaxDepth int
+
+	// AllowUnknown allows keys, which don't correspond to flags
+	// registered in flag.CommandLine.
+	AllowUnknown bool
+
+	// FS is used for opening local config files, including file:// urls.
+	// The OS filesystem is used if FS is nil.
+	FS fs.FS
+}
+
+// ReadIniFileWithOptions reads flag values from the given ini file
+// without applying them.
+//
+// Unlike ReadIniFile(), the file is parsed according to the given options
+// only, so global iniflags settings don't affect the result. This makes
+// the function suitable for config validators and diff tools.
+func ReadIniFileWithOptions(path string, opts ReadOptions) ([]FlagArg, error) {
+	p := &configParser{
+		allowMissing: opts.AllowMissing,
+		maxDepth:     opts.MaxDepth,
+		fsys:         opts.FS,
+		unsecure:     *unsecure,
+		userAgent:    httpUserAgent,
+		isSecret:     isSecretKey,
+		logDebug:     true,
+	}
+	args, err := p.parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.AllowUnknown {
+		for _, arg := range args {
+			if flag.Lookup(arg.Key) == nil && flagShorthands[arg.Key] == "" {
+				return nil, fmt.Errorf("iniflags: unknown flag name=[%s] found at line [%d] of file [%s]", arg.Key, arg.LineNum, arg.FilePath)
+			}
+		}
+	}
+	return args, nil
+}
+
+func getArgsFromConfig(configPath string) (args []FlagArg, ok bool) {
+	args, err := newConfigParser().parseFile(configPath)
+	if err != nil {
+		reportConfigError(ParseError{FilePath: configPath, Err: err}, err.Error())
+		return nil, false
+	}
+	return args, true
+}
+
+// getArgsFromConfigURLs reads the config from the first url in urls,
+// which can be successfully fetched.
+//
+// The returned configPath contains the url the config has been read from.
+func getArgsFromConfigURLs(urls []string) (args []FlagArg, configPath string, ok bool) {
+	p := newConfigParser()
+	for i, u := range urls {
+		logger.Printf("iniflags: loading config from [%s] (url %d of %d)", u, i+1, len(urls))
+		file, err := p.open(u)
+		if err != nil {
+			logger.Printf("%s", err)
+			continue
+		}
+		args, err = p.parseReader(u, file)
+		file.Close()
+		if err != nil {
+			reportConfigError(ParseError{FilePath: u, Err: err}, err.Error())
+			return nil, "", false
+		}
+		return args, u, true
+	}
+	logger.Printf("iniflags: cannot load config from any of the urls %v", urls)
+	return nil, "", *allowMissingConfig
+}
+
+// configParser reads flag values from config files.
+type configParser struct {
+	allowMissing bool
+	maxDepth     int
+	fsys         fs.FS
+
+	preprocessor   func(raw []byte) ([]byte, error)
+	lineTransform  func(line string, lineNum int, path string) string
+	contentHooks   []func(path string, content []byte) ([]byte, error)
+	formats        map[string]Format
+	format         string
+	tomlFlagsTable string
+	sectionFilter  map[string]bool
+	environment    string
+	pathMapper     func(rawPath string) string
+	separator      string
+	ctx            context.Context
+
+	// http settings
+	unsecure  bool
+	userAgent string
+	noCache   bool
+
+	// isSecret returns true if the value for the given key must be redacted
+	// in errors and debug messages.
+	isSecret func(key string) bool
+
+	// logDebug enables debug messages at LogDebug level.
+	logDebug bool
+
+	rejectControlChars bool
+	lowercaseSections  bool
+
+	importStack []string
+	importGraph *ImportNode
+}
+
+// getParseContext returns the context for fetching configs
+// during the current parse.
+func getParseContext() context.Context {
+	if parseCtx != nil {
+		return parseCtx
+	}
+	return context.Background()
+}
+
+// getProgramPath returns the path to the program, which is used as a base
+// for relative -config paths.
+//
+// The path set via SetExecutablePath() takes precedence over os.Executable(),
+// while args[0] passed to Parse() is used only if os.Executable() fails.
+func getProgramPath() string {
+	if executablePath != "" {
+		return executablePath
+	}
+	if exe, err := os.Executable(); err == nil {
+		return exe
+	}
+	if programPath != "" {
+		return programPath
+	}
+	return os.Args[0]
+}
+
+// newConfigParser returns configParser set up according to global settings.
+func newConfigParser() *configParser {
+	noCache := reloading
+	if cacheBusting != nil {
+		noCache = *cacheBusting
+	}
+	p := &configParser{
+		allowMissing:   *allowMissingConfig,
+		preprocessor:   preprocessor,
+		lineTransform:  lineTransform,
+		contentHooks:   configReloadHooks,
+		formats:        configFormats,
+		format:         configFormat,
+		tomlFlagsTable: tomlFlagsTable,
+		sectionFilter:  configSectionFilter,
+		environment:    environment,
+		pathMapper:     configPathMapper,
+		separator:      assignmentSeparator,
+		ctx:            getParseContext(),
+		unsecure:       *unsecure,
+		userAgent:      httpUserAgent,
+		noCache:        noCache,
+		isSecret:       isSecretKey,
+		logDebug:       true,
+
+		rejectControlChars: rejectControlChars,
+		lowercaseSections:  lowercaseSections,
+	}
+	if lowercaseSections {
+		p.tomlFlagsTable = strings.ToLower(p.tomlFlagsTable)
+		if p.sectionFilter != nil {
+			p.sectionFilter = make(map[string]bool, len(configSectionFilter))
+			for section := range configSectionFilter {
+				p.sectionFilter[strings.ToLower(section)] = true
+			}
+		}
+	}
+	return p
+}
+
+func (p *configParser) checkImportRecursion(configPath string) error {
+	for _, path := range p.importStack {
+		if path == configPath {
+			return fmt.Errorf("iniflags: import recursion found for [%s]: %v", configPath, p.importStack)
+		}
+	}
+	if p.maxDepth > 0 && len(p.importStack) > p.maxDepth {
+		return fmt.Errorf("iniflags: cannot import [%s]: import depth exceeds %d: %v", configPath, p.maxDepth, p.importStack)
+	}
+	return nil
+}
+
+// parseFile reads flag values from the config file at the given path.
+func (p *configParser) parseFile(configPath string) ([]FlagArg, error) {
+	if err := p.checkImportRecursion(configPath); err != nil {
+		return nil, err
+	}
+	file, err := p.open(configPath)
+	if err != nil {
+		if p.allowMissing {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	format := p.lookupFormat(configPath)
+	if format == IniFormat {
+		return p.parseReader(configPath, file)
+	}
+	args, err := format.Parse(file, configPath)
+	if err != nil {
+		return nil, fmt.Errorf("iniflags: cannot parse config file [%s]: %s", configPath, err)
+	}
+	for i := range args {
+		if args[i].FilePath == "" {
+			args[i].FilePath = configPath
+		}
+	}
+	return args, nil
+}
+
+// parseImport reads flag values from the config file imported
+// at the given line of the currently parsed file.
+//
+// The import is recorded in p.importGraph if it is set. Import cycles
+// are recorded instead of returning an error in this case.
+func (p *configParser) parseImport(importPath string, lineNum int) ([]FlagArg, error) {
+	parent := p.importGraph
+	if parent == nil {
+		return p.parseFile(importPath)
+	}
+	node := newImportNode(importPath, lineNum)
+	parent.Imports = append(parent.Imports, node)
+	for _, path := range p.importStack {
+		if path == importPath {
+			node.Cycle = true
+			return nil, nil
+		}
+	}
+	p.importGraph = node
+	defer func() {
+		p.importGraph = parent
+	}()
+	return p.parseFile(importPath)
+}
+
+func (p *configParser) open(configPath string) (io.ReadCloser, error) {
+	if isFileURL(configPath) {
+		// file:// urls address local files, so they are opened
+		// via fsys and pathMapper as well.
+		filePath, err := fileURLPath(configPath)
+		if err != nil {
+			return nil, err
+		}
+		configPath = filePath
+	}
+	if p.fsys != nil && !isURL(configPath) {
+		file, err := p.fsys.Open(strings.TrimPrefix(path.Clean(configPath), "/"))
+		if err != nil {
+			return nil, fmt.Errorf("iniflags: cannot open config file at [%s]: [%s]", configPath, err)
+		}
+		return file, nil
+	}
+	if p.pathMapper != nil && !isURL(configPath) {
+		configPath = p.pathMapper(configPath)
+	}
+	return p.openConfigFile(configPath)
+}
+
+// debugf logs the message at LogDebug level if p.logDebug is set.
+func (p *configParser) debugf(format string, args ...interface{}) {
+	if p.logDebug {
+		debugf(format, args...)
+	}
+}
+
+// parseReader reads flag values from r containing the config file
+// at the given path.
+func (p *configParser) parseReader(configPath string, file io.Reader) (args []FlagArg, err error) {
+	p.importStack = append(p.importStack, configPath)
+	defer func() {
+		p.importStack = p.importStack[:len(p.importStack)-1]
+	}()
+
+	if p.preprocessor != nil || len(p.contentHooks) > 0 {
+		raw, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("iniflags: error when reading file [%s]: [%s]", configPath, err)
+		}
+		if p.preprocessor != nil {
+			if raw, err = p.preprocessor(raw); err != nil {
+				return nil, fmt.Errorf("iniflags: error when preprocessing file [%s]: [%s]", configPath, err)
+			}
+		}
+		for _, hook := range p.contentHooks {
+			if raw, err = hook(configPath, raw); err != nil {
+				return nil, fmt.Errorf("iniflags: error when transforming file [%s]: [%s]", configPath, err)
+			}
+		}
+		file = bytes.NewReader(raw)
+	}
+	r := bufio.NewReader(file)
+
+	var lineNum int
+	var comment = ""
+	var section string
+	var multilineFA FlagArg
+	for {
+		lineNum++
+		line, err := r.ReadString('\n')
+
+		if err != nil && line == "" {
+			if err == io.EOF {
+				if len(multilineFA.Key) > 0 {
+					// flush the last multiline arg
+					args = append(args, multilineFA)
+				}
+				break
+			}
+			return nil, fmt.Errorf("iniflags: error when reading file [%s] at line %d: [%s]", configPath, lineNum, err)
+		}
+
+		// check if line is encoded in UTF-8
+		if !utf8.ValidString(line) {
+			return nil, fmt.Errorf("iniflags: invalid UTF-8 encoding at line %d of file [%s]", lineNum, configPath)
+		}
+
+		if lineNum == 1 {
+			line = stripBOM(line)
+		}
+		if p.lineTransform != nil {
+			line = p.lineTransform(strings.TrimRight(line, "\r\n"), lineNum, configPath)
+		}
+		line = strings.TrimSpace(line)
+		if line != "" && line[0] == '[' {
+			section = sectionName(line)
+			if p.lowercaseSections {
+				section = strings.ToLower(section)
+			}
+			comment = ""
+			continue
+		}
+		if p.tomlFlagsTable != "" && section != p.tomlFlagsTable ||
+			p.sectionFilter != nil && section != "" && !p.sectionFilter[section] {
+			// skip lines outside the flags table and in sections not matching the filter.
+			// Heredoc bodies are skipped as a whole, so body lines looking like
+			// section headers don't switch the section.
+			if terminator, ok := p.lineHeredocTerminator(line); ok {
+				_, n, err := readHeredoc(r, terminator)
+				if err != nil {
+					return nil, fmt.Errorf("iniflags: %s in heredoc started at line %d in config file [%s]", err, lineNum, configPath)
+				}
+				lineNum += n
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#import ") {
+			importPath, _, err := parseValue(line[7:])
+			if err != nil {
+				return nil, fmt.Errorf("iniflags: %s at line %d in config file [%s]", err, lineNum, configPath)
+			}
+			if importPath, err = resolvePath(configPath, importPath); err != nil {
+				return nil, err
+			}
+			importArgs, err := p.parseImport(importPath, lineNum)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, importArgs...)
+			continue
+		}
+		if line == "" {
+			comment = ""
+			continue
+		}
+		if line[0] == '#' || line[0] == ';' {
+			//save the comment and move to the next line
+			comment = line[1:]
+			continue
+		}
+		parts := strings.SplitN(line, p.getSeparator(), 2)
+		if len(parts) != 2 {
+			// The line isn't included in the error, since it may contain a secret value.
+			return nil, fmt.Errorf("iniflags: cannot split line %d into key and value separated by [%s] in config file [%s]", lineNum, p.getSeparator(), configPath)
+		}
+		key, typ := splitKeyType(strings.TrimSpace(parts[0]))
+		keyLineNum := lineNum
+
+		var value, cmt string
+		if terminator, ok := heredocTerminator(parts[1]); ok {
+			var n int
+			if value, n, err = readHeredoc(r, terminator); err != nil {
+				return nil, fmt.Errorf("iniflags: %s in heredoc started at line %d in config file [%s]", err, lineNum, configPath)
+			}
+			if p.rejectControlChars {
+				if err := checkControlChars(value); err != nil {
+					return nil, fmt.Errorf("iniflags: %s in heredoc started at line %d in config file [%s]", err, lineNum, configPath)
+				}
+			}
+			lineNum += n
+		} else {
+			redact := p.isSecret != nil && p.isSecret(key)
+			if value, cmt, err = parseValueRedacted(parts[1], redact); err != nil {
+				return nil, fmt.Errorf("iniflags: %s at line %d in config file [%s]", err, lineNum, configPath)
+			}
+			if strings.HasPrefix(strings.TrimSpace(parts[1]), "\"") {
+				if redact {
+					p.debugf("iniflags: unquoted value [%s]", redactedValue)
+				} else {
+					p.debugf("iniflags: unquoted value [%s]", value)
+				}
+				p.debugf("iniflags: comment [%s]", cmt)
+			}
+			if p.rejectControlChars {
+				if err := checkControlChars(value); err != nil {
+					return nil, fmt.Errorf("iniflags: %s at line %d in config file [%s]", err, lineNum, configPath)
+				}
+			}
+			if p.environment != "" && !strings.HasPrefix(strings.TrimSpace(parts[1]), "\"") {
+				value = selectConditionalValue(value, p.environment)
+			}
+		}
+		if comment == "" {
+			comment = cmt
+		}
+
+		fa := FlagArg{
+			Key:      key,
+			Value:    value,
+			Type:     typ,
+			FilePath: configPath,
+			LineNum:  keyLineNum,
+			Comment:  comment,
+		}
+
+		comment = ""
+		if !strings.HasSuffix(key, "}") {
+			if len(multilineFA.Key) > 0 {
+				// flush the last multiline arg
+				args = append(args, multilineFA)
+				multilineFA = FlagArg{}
+			}
+
+			args = append(args, fa)
+			continue
+		}
+
+		// multiline arg
+		n := strings.LastIndex(key, "{")
+		if n < 0 {
+			return nil, fmt.Errorf("iniflags: cannot find '{' in the multiline key [%s] at line %d, file [%s]", key, lineNum, configPath)
+		}
+		switch multilineFA.Key {
+		case "":
+			// the first line for multiline arg
+			multilineFA = fa
+			multilineFA.Key = key[:n]
+		case key[:n]:
+			// the subsequent line for multiline arg
+			delimiter := key[n+1 : len(key)-1]
+			multilineFA.Value += delimiter
+			multilineFA.Value += value
+		default:
+			// new multiline arg
+			args = append(args, multilineFA)
+			multilineFA = fa
+			multilineFA.Key = key[:n]
+		}
+	}
+
+	return args, nil
+}
+
+// heredocTerminator returns the terminator for the heredoc value
+// in the form "<<END".
+func heredocTerminator(val string) (string, bool) {
+	val = strings.TrimSpace(val)
+	if !strings.HasPrefix(val, "<<") {
+		return "", false
+	}
+	terminator := val[2:]
+	if terminator == "" || !isSelector(terminator) {
+		return "", false
+	}
+	return terminator, true
+}
+
+// getSeparator returns the separator between keys and values.
+func (p *configParser) getSeparator() string {
+	if p.separator == "" {
+		return "="
+	}
+	return p.separator
+}
+
+// lineHeredocTerminator returns the heredoc terminator for the config line
+// such as "key = <<END".
+func (p *configParser) lineHeredocTerminator(line string) (string, bool) {
+	if line == "" || line[0] == '#' || line[0] == ';' {
+		return "", false
+	}
+	parts := strings.SplitN(line, p.getSeparator(), 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	return heredocTerminator(parts[1])
+}
+
+// readHeredoc reads lines from r up to the line containing only
+// the given terminator.
+//
+// It returns the lines verbatim without the trailing newline
+// and the number of lines read including the terminator line.
+func readHeredoc(r *bufio.Reader, terminator string) (string, int, error) {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && line == "" {
+			if err == io.EOF {
+				return "", 0, fmt.Errorf("missing terminator [%s]", terminator)
+			}
+			return "", 0, err
+		}
+		if !utf8.ValidString(line) {
+			return "", 0, fmt.Errorf("invalid UTF-8 encoding at heredoc line %d", len(lines)+1)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == terminator {
+			return strings.Join(lines, "\n"), len(lines) + 1, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// sectionName returns the name of the section from the given
+// section header line such as "[section]".
+func sectionName(line string) string {
+	line = line[1:]
+	if n := strings.IndexByte(line, ']'); n >= 0 {
+		line = line[:n]
+	}
+	return strings.TrimSpace(line)
+}
+
+// unencryptedConfigURLs contains config urls loaded over plain http,
+// which have been already warned about.
+var unencryptedConfigURLs sync.Map
+
+func (p *configParser) openConfigFile(path string) (io.ReadCloser, error) {
+	if err := checkConfigPath(path); err != nil {
+		return nil, err
+	}
+	if isFileURL(path) {
+		filePath, err := fileURLPath(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("iniflags: cannot open config file at [%s]: [%s]", path, err)
+		}
+		return file, nil
+	}
+	if isURL(path) {
+		var resp *http.Response
+		var err error
+		// check path if it is secure
+		if isSecure(path) {
+			// It's a https path, so no need to check if unsecure is set
+			resp, err = p.httpGet(path)
+		} else {
+			if !p.unsecure {
+				return nil, fmt.Errorf("iniflags: cannot load config file at [%s]: unsecure communication is not allowed; use https or pass -unsecure", path)
+			} else {
+				// warn if unsecure is set and the path is not secure.
+				// The warning is logged once per path, so config reloads don't flood the log.
+				if _, warned := unencryptedConfigURLs.LoadOrStore(path, true); !warned {
+					logger.Printf("iniflags: WARNING: loading config file at [%s] over unencrypted http. "+
+						"Values such as passwords or API keys in this config could be intercepted or modified in transit; use https instead", path)
+				}
+				resp, err = p.httpGet(path)
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("iniflags: cannot load config file at [%s]: [%s]", path, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("iniflags: unexpected http status code when obtaining config file [%s]: %d. Expected %d", path, resp.StatusCode, http.StatusOK)
+		}
+		return resp.Body, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("iniflags: cannot open config file at [%s]: [%s]", path, err)
+	}
+	return file, nil
+}
+
+// httpGet fetches the given url, identifying itself with p.userAgent.
+func (p *configParser) httpGet(url string) (*http.Response, error) {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+	if p.noCache {
+		req.Header.Set("Cache-Control", "no-cache")
+		req.Header.Set("Pragma", "no-cache")
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// moduleVersion returns the version of the iniflags module the application
+// was built with, or "(devel)" if it cannot be determined.
+func moduleVersion() string {
+	const modulePath = "github.com/boomhut/iniflags"
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if bi.Main.Path == modulePath && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		if dep.Version != "" {
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+func combinePath(basePath, relPath string) (string, bool) {
+	p, err := resolvePath(basePath, relPath)
+	if err != nil {
+		logger.Printf("%s", err)
+		return "", false
+	}
+	return p, true
+}
+
+// resolvePath resolves relPath relative to basePath.
+func resolvePath(basePath, relPath string) (string, error) {
+	if err := checkConfigPath(relPath); err != nil {
+		return "", err
+	}
+	if isURL(basePath) {
+		base, err := url.Parse(basePath)
+		if err != nil {
+			return "", fmt.Errorf("iniflags: error when parsing base url [%s]: %s", basePath, err)
+		}
+		rel, err := url.Parse(relPath)
+		if err != nil {
+			return "", fmt.Errorf("iniflags: error when parsing rel path [%s] for base url [%s]: %s", relPath, basePath, err)
+		}
+		return base.ResolveReference(rel).String(), nil
+	}
+
+	if relPath == "" || relPath[0] == '/' || isURL(relPath) {
+		return relPath, nil
+	}
+	return path.Join(path.Dir(basePath), relPath), nil
+}
+
+// maxConfigPathLen is the maximum length of config path.
+//
+// It matches PATH_MAX on Linux.
+const maxConfigPathLen = 4096
+
+// checkConfigPath verifies that the given config path may be safely opened.
+func checkConfigPath(path string) error {
+	if strings.IndexByte(path, 0) >= 0 {
+		return fmt.Errorf("iniflags: config path %q contains null byte", path)
+	}
+	if len(path) > maxConfigPathLen {
+		return fmt.Errorf("iniflags: config path is too long: %d bytes; it mustn't exceed %d bytes", len(path), maxConfigPathLen)
+	}
+	return nil
+}
+
+func isURL(path string) bool {
+	s := strings.ToLower(path)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "file://")
+}
+
+func isFileURL(path string) bool {
+	return strings.HasPrefix(strings.ToLower(path), "file://")
+}
+
+// fileURLPath returns local path for the given file:// url.
+//
+// Only file:///absolute/path and file://localhost/absolute/path
+// urls are supported. Windows paths are addressed as file:///C:/path.
+func fileURLPath(fileURL string) (string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", fmt.Errorf("iniflags: cannot parse file url [%s]: %s", fileURL, err)
+	}
+	if u.Host != "" && !strings.EqualFold(u.Host, "localhost") {
+		return "", fmt.Errorf("iniflags: unsupported host [%s] in file url [%s]; only empty host and localhost are allowed", u.Host, fileURL)
+	}
+	if u.Path == "" {
+		return "", fmt.Errorf("iniflags: missing path in file url [%s]", fileURL)
+	}
+	return localFilePath(u.Path, runtime.GOOS), nil
+}
+
+// localFilePath converts the path from file url to the local file path
+// for the given goos.
+func localFilePath(urlPath, goos string) string {
+	if goos != "windows" {
+		return urlPath
+	}
+	if len(urlPath) >= 3 && urlPath[0] == '/' && urlPath[2] == ':' && isDriveLetter(urlPath[1]) {
+		// strip the leading slash from /C:/path
+		urlPath = urlPath[1:]
+	}
+	return strings.ReplaceAll(urlPath, "/", `\`)
+}
+
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isSecure(path string) bool {
+	return strings.HasPrefix(strings.ToLower(path), "https://")
+}
+
+// getMissingFlags returns flags, which may be set via config files, e.g.
+// flags not set via command line or SetOverrides().
+//
+// Flags set via environment variables are included, since config values
+// override them.
+func getMissingFlags() map[string]bool {
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	missingFlags := make(map[string]bool)
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, ok := setFlags[f.Name]; !ok && !overrideFlags[f.Name] {
+			missingFlags[f.Name] = true
+		}
+	})
+	return missingFlags
+}
+
+func dumpFlags() {
+	DumpFlagsToWriter(os.Stdout)
+}
+
+// DumpFlagsToWriter writes values for all the flags defined in the application
+// to w in ini-compatible syntax.
+//
+// Flags excluded via ExcludeFlagFromDump() aren't written.
+func DumpFlagsToWriter(w io.Writer) error {
+	return writeFlags(w, false, false)
+}
+
+// DumpFlagsWithChanges works like DumpFlagsToWriter, but additionally marks
+// flags with values differing from their defaults with a comment line
+// containing the default value.
+//
+// This makes customized settings stand out in large dumps, while the output
+// remains valid ini.
+func DumpFlagsWithChanges(w io.Writer) error {
+	return writeFlags(w, true, false)
+}
+
+// DumpChangedFlags works like DumpFlagsToWriter, but writes only flags
+// with values differing from their defaults.
+//
+// This produces a minimal config capturing the effective non-default
+// settings of the running app. The same output is written to stdout
+// by -dumpchanged.
+func DumpChangedFlags(w io.Writer) error {
+	return writeFlags(w, false, true)
+}
+
+// SetDumpFilter sets the filter for flags written by DumpFlagsToWriter(),
+// DumpFlagsWithChanges() and -dumpflags.
+//
+// Flags for which filter returns false are omitted. This is useful for
+// excluding volatile flags such as build dates from dumps compared against
+// golden files in tests. Pass nil for dumping all the flags.
+func SetDumpFilter(filter func(name string) bool) {
+	dumpFilter = filter
+}
+
+// SetDumpCommentChar sets the char used for comments in -dumpflags output.
+//
+// The char must be either '#' (the default) or ';'. The latter is useful
+// for generating configs for tools, which recognize only ';' comments.
+func SetDumpCommentChar(char rune) {
+	if char != '#' && char != ';' {
+		logger.Panicf("iniflags: SetDumpCommentChar() accepts only '#' or ';'; got %q", char)
+	}
+	dumpCommentChar = char
+}
+
+// Dumper may be implemented by flag values in order to control their
+// representation in -dumpflags output.
+//
+// DumpString must return the value in the form accepted by the value's
+// Set method, so the dumped config may be read back. Values not implementing
+// Dumper are dumped via String().
+type Dumper interface {
+	DumpString() string
+}
+
+func dumpValue(v flag.Value) string {
+	if d, ok := v.(Dumper); ok {
+		return d.DumpString()
+	}
+	return v.String()
+}
+
+func writeFlags(w io.Writer, markChanges, onlyChanged bool) error {
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, exclude := flagsToExcludeFromDump[f.Name]; exclude || isSecretFlag(f.Name) || err != nil {
+			return
+		}
+		if dumpFilter != nil && !dumpFilter(f.Name) {
+			return
+		}
+		changed := f.Value.String() != f.DefValue
+		if onlyChanged && !changed {
+			return
+		}
+		value := dumpValue(f.Value)
+		if markChanges && changed {
+			if _, err = fmt.Fprintf(w, "%c (changed from default: %s)\n", dumpCommentChar, quoteValue(f.DefValue)); err != nil {
+				return
+			}
+		}
+		_, err = fmt.Fprintf(w, "%s = %s  %c %s\n", f.Name, quoteValue(value), dumpCommentChar, escapeUsage(f.Usage))
+	})
+	return err
+}
+
+// escapeUsage escapes the usage string so it can be used as a comment in an ini file.
+func escapeUsage(s string) string {
+	// escape all the special characters that are not allowed. (tab, vertical tab, form feed, backspace, alert, backslash, double quote, superscript 2, superscript 3, superscript 1, superscript 0, superscript 4, superscript 5, superscript 6, superscript 7, superscript 8, superscript 9)
+	stringsToReplace := []string{"\t", "\v", "\f", "\b", "\a", "\\", "\"", "\u00B2", "\u00B3", "\u00B9", "\u2070", "\u2074", "\u2075", "\u2076", "\u2077", "\u2078", "\u2079"}
+	s = strings.Replace(s, "\n", "\n    "+string(dumpCommentChar)+" ", -1)
+	for _, str := range stringsToReplace {
+		s = strings.Replace(s, str, "", -1)
+	}
+
+	return s
+}
+
+// quoteValue quotes v if it cannot be read back as is from ini file.
+func quoteValue(v string) string {
+	if !strings.ContainsAny(v, "\n#;?") && strings.TrimSpace(v) == v && !strings.HasPrefix(v, "\"") && !strings.HasPrefix(v, "<<") {
+		return v
+	}
+	v = strings.Replace(v, "\\", "\\\\", -1)
+	v = strings.Replace(v, "\n", "\\n", -1)
+	v = strings.Replace(v, "\"", "\\\"", -1)
+	return fmt.Sprintf("\"%s\"", v)
+}
+
+func unquoteValue(val string, lineNum int, configPath string) (string, string, bool) {
+	v, comment, err := parseValue(val)
+	if err != nil {
+		logger.Printf("iniflags: %s at line %d in config file [%s]", err, lineNum, configPath)
+		return "", "", false
+	}
+	return v, comment, true
+}
+
+// parseValue returns the unquoted value and the trailing comment
+// from the value part of config line.
+func parseValue(val string) (string, string, error) {
+	return parseValueRedacted(val, false)
+}
+
+// parseValueRedacted works like parseValue, but redacts the value
+// in errors if redact is set.
+func parseValueRedacted(val string, redact bool) (string, string, error) {
+	v := strings.TrimSpace(val)
+	if len(v) == 0 {
+		return "", "", nil
+	}
+	if v[0] != '"' {
+		return removeTrailingComments(v), getTrailingComment(v), nil
+	}
+	start := strings.IndexByte(val, '"')
+	v, n, err := unescapeQuoted(val[start+1:])
+	if err != nil {
+		if redact {
+			return "", "", fmt.Errorf("unclosed string found [%s]", redactedValue)
+		}
+		return "", "", err
+	}
+	comment := getTrailingComment(val[start+1+n:])
+	return v, comment, nil
+}
+
+// unescapeQuoted unescapes s up to the closing double quote.
+//
+// It returns the unescaped value and the number of bytes consumed
+// from s including the closing quote. Only \\, \" and \n escape sequences
+// are supported, other backslashes are left as is.
+func unescapeQuoted(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(s) {
+				switch s[i+1] {
+				case '\\', '"':
+					b.WriteByte(s[i+1])
+					i++
+					continue
+				case 'n':
+					b.WriteByte('\n')
+					i++
+					continue
+				}
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unclosed string found [\"%s]", strings.TrimSpace(s))
+}
+
+// selectConditionalValue evaluates the conditional value in the form
+// "selector?ifMatch:else" against the given environment.
+//
+// Values without a valid selector before '?' or without ':' after '?',
+// such as urls with query args or "Ready?", are returned as is.
+func selectConditionalValue(v, environment string) string {
+	n := strings.IndexByte(v, '?')
+	if n <= 0 || !isSelector(v[:n]) {
+		return v
+	}
+	selector, rest := v[:n], v[n+1:]
+	m := strings.IndexByte(rest, ':')
+	if m < 0 {
+		return v
+	}
+	if selector == environment {
+		return rest[:m]
+	}
+	return rest[m+1:]
+}
+
+// checkControlChars returns an error if the parsed value v contains
+// control chars.
+//
+// Newlines are allowed, since they may appear only in heredoc bodies
+// or via "\n" escape sequences in quoted values.
+func checkControlChars(v string) error {
+	for i, c := range v {
+		if unicode.IsControl(c) && c != '\n' {
+			// The value isn't included in the error, since it may be a secret.
+			return fmt.Errorf("control char %U found at position %d of the value", c, i)
+		}
+	}
+	return nil
+}
+
+func isSelector(s string) bool {
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// DecodeConfigLine decodes a single line of ini config in the format
+// supported by iniflags.
+//
+// It returns the key, the unquoted and unescaped value and the trailing
+// comment. Keys are returned as written, so keys of multiline values
+// such as "list{,}" retain the delimiter suffix, and keys with type
+// annotations such as "timeout:duration" retain the type. Empty lines
+// and comment lines return an empty key; the comment is returned
+// for the latter. The key-value separator set via SetAssignmentSeparator()
+// is used.
+//
+// Section headers, #import directives and heredoc values span more than
+// key-value syntax and must be handled by the caller.
+func DecodeConfigLine(line string) (key, value, comment string, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", "", "", nil
+	}
+	if line[0] == '#' || line[0] == ';' {
+		return "", "", strings.TrimSpace(line[1:]), nil
+	}
+	// The line isn't included in errors, since it may contain a secret value.
+	parts := strings.SplitN(line, assignmentSeparator, 2)
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("iniflags: cannot split the line into key and value separated by [%s]", assignmentSeparator)
+	}
+	key = strings.TrimSpace(parts[0])
+	if key == "" {
+		return "", "", "", fmt.Errorf("iniflags: missing key before [%s]", assignmentSeparator)
+	}
+	if value, comment, err = parseValueRedacted(parts[1], isSecretKey(key)); err != nil {
+		return "", "", "", fmt.Errorf("iniflags: %s in the value for key [%s]", err, key)
+	}
+	return key, value, strings.TrimSpace(comment), nil
+}
+
+// QuoteConfigValue returns v formatted for the value part of a config line.
+//
+// Values, which could be misinterpreted by the config parser, such as values
+// with comment chars, newlines or surrounding whitespace, are quoted
+// and escaped. UnquoteConfigValue(QuoteConfigValue(v)) == v for any v.
+func QuoteConfigValue(v string) string {
+	return quoteValue(v)
+}
+
+// UnquoteConfigValue returns the value written in the value part
+// of a config line, e.g. the part following "key =".
+//
+// Quoted values are unescaped, while trailing comments are removed.
+// The \\, \" and \n escape sequences are supported in quoted values.
+// This is the inverse of the quoting applied when flags are dumped.
+// The returned error doesn't contain raw, since it may hold a secret.
+func UnquoteConfigValue(raw string) (string, error) {
+	v, _, err := parseValueRedacted(raw, true)
+	if err != nil {
+		return "", fmt.Errorf("iniflags: %s", err)
+	}
+	return v, nil
+}
+
+func removeTrailingComments(v string) string {
+	if n := commentStart(v); n >= 0 {
+		v = v[:n]
+	}
+	return strings.TrimSpace(v)
+}
+
+func getTrailingComment(v string) string {
+	if len(v) == 0 {
+		return ""
+	}
+	if v[0] == '"' {
+		return ""
+	}
+	if n := commentStart(v); n >= 0 {
+		return v[n+1:]
+	}
+	return ""
+}
+
+// commentStart returns the index of '#' or ';' starting the trailing
+// comment in v or -1 if v has no trailing comment.
+//
+// The comment must start either at the beginning of v or after whitespace,
+// so values such as "http://host/#fragment" or "a;b" are left intact.
+func commentStart(v string) int {
+	for i := 0; i < len(v); i++ {
+		if v[i] != '#' && v[i] != ';' {
+			continue
+		}
+		if i == 0 || v[i-1] == ' ' || v[i-1] == '\t' {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetConfigFile sets path to config file.
+//
+// Call this function before Parse() if you need default path to config file
+// when -config command-line flag is not set.
+//
+// The path is used only if neither -config is passed via command line
+// nor config files are added via AddConfigFile().
+func SetConfigFile(path string) {
+	if parsed {
+		logger.Panicf("iniflags: SetConfigFile() must be called before Parse()")
+	}
+	*config = path
+}
+
+// defaultConfigName is the config path reported for the content set
+// via SetDefaultConfigContent().
+const defaultConfigName = "<default config>"
+
+// SetDefaultConfigContent sets ini config content, which is used
+// if no config source is set or none of the local config files exist.
+//
+// This allows embedding the baseline config into the binary, e.g. via
+// go:embed, while it may be replaced via -config. Flags set via command line,
+// environment variables or SetOverrides() take precedence over the content
+// as usual. #import directives in the content are resolved relative
+// to the current working directory. Must be called before Parse().
+func SetDefaultConfigContent(content string) {
+	if parsed {
+		logger.Panicf("iniflags: SetDefaultConfigContent() must be called before Parse()")
+	}
+	defaultConfigContent = content
+}
+
+// SetExecutablePath sets the path used as a base for relative config paths.
+//
+// By default relative config paths are resolved against os.Executable(),
+// which doesn't depend on the way the program has been started, unlike
+// os.Args[0]. Must be called before Parse().
+func SetExecutablePath(path string) {
+	if parsed {
+		logger.Panicf("iniflags: SetExecutablePath() must be called before Parse()")
+	}
+	executablePath = path
+}
+
+// AddConfigFile appends the given path to the list of config files to load.
+//
+// Files are loaded in the order they were added, so later files override
+// earlier ones. The list takes precedence over SetConfigFile(), while
+// -config passed via command line and INIFLAGS_CONFIG environment variable
+// take precedence over the list.
+//
+// Must be called before Parse().
+func AddConfigFile(path string) {
+	if parsed {
+		logger.Panicf("iniflags: AddConfigFile() must be called before Parse()")
 	}
-
-	// // check if file is properly formatted UTF-8
-	// if !utf8.ValidString(file) {
-	// 	logger.Printf("iniflags: config file at [%s] is not properly formatted UTF-8", path)
-	// 	return nil, err
-	// }
-
-	return file, nil
+	addedConfigFiles = append(addedConfigFiles, path)
 }
 
-func combinePath(basePath, relPath string) (string, bool) {
-	if isHTTP(basePath) {
-		base, err := url.Parse(basePath)
-		if err != nil {
-			logger.Printf("iniflags: error when parsing http base path [%s]: %s", basePath, err)
-			return "", false
-		}
-		rel, err := url.Parse(relPath)
-		if err != nil {
-			logger.Printf("iniflags: error when parsing http rel path [%s] for base [%s]: %s", relPath, basePath, err)
-			return "", false
-		}
-		return base.ResolveReference(rel).String(), true
+func SetAllowMissingConfigFile(allowed bool) {
+	if parsed {
+		panic("iniflags: SetAllowMissingConfigFile() must be called before Parse()")
 	}
+	*allowMissingConfig = allowed
+}
 
-	if relPath == "" || relPath[0] == '/' || isHTTP(relPath) {
-		return relPath, true
+func SetAllowUnknownFlags(allowed bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetAllowUnknownFlags() must be called before Parse()")
 	}
-	return path.Join(path.Dir(basePath), relPath), true
+	*allowUnknownFlags = allowed
 }
 
-func isHTTP(path string) bool {
-	return strings.HasPrefix(strings.ToLower(path), "http://") || strings.HasPrefix(strings.ToLower(path), "https://")
+// SetConfigUpdateInterval sets the interval for periodic config reload
+// like -configUpdateInterval command-line flag does.
+//
+// It may be called after Parse() in order to change the interval
+// at runtime. Zero interval stops periodic config reload.
+func SetConfigUpdateInterval(interval time.Duration) {
+	if !parsed {
+		*configUpdateInterval = interval
+		return
+	}
+	reloadMu.Lock()
+	*configUpdateInterval = interval
+	reloadMu.Unlock()
+	setUpdateInterval(interval)
 }
 
-func isSecure(path string) bool {
-	return strings.HasPrefix(strings.ToLower(path), "https://")
+// SetConfigURLs sets a list of urls to load the config from.
+//
+// The urls are tried in order and the config is read from the first url,
+// which returns 200 OK. This allows hosting the same config on multiple
+// mirrors. The urls are used only if the config path isn't set via -config
+// or SetConfigFile(). The url the config has been loaded from is available
+// via LoadedConfigPath().
+func SetConfigURLs(urls ...string) {
+	if parsed {
+		logger.Panicf("iniflags: SetConfigURLs() must be called before Parse()")
+	}
+	configURLs = append([]string(nil), urls...)
 }
 
-func getMissingFlags() map[string]bool {
-	setFlags := make(map[string]bool)
-	flag.Visit(func(f *flag.Flag) {
-		setFlags[f.Name] = true
-	})
-
-	missingFlags := make(map[string]bool)
-	flag.VisitAll(func(f *flag.Flag) {
-		if _, ok := setFlags[f.Name]; !ok {
-			missingFlags[f.Name] = true
-		}
-	})
-	return missingFlags
+// LoadedConfigPath returns the path or url of the config file
+// the flags were read from during the last successful config load.
+//
+// If multiple config files have been loaded, the path of the last one
+// is returned, since its values take precedence. Use LoadedConfigPaths()
+// for obtaining all the paths. It returns an empty string if no config file
+// has been loaded.
+func LoadedConfigPath() string {
+	if len(loadedConfigPaths) == 0 {
+		return ""
+	}
+	return loadedConfigPaths[len(loadedConfigPaths)-1]
 }
 
-func dumpFlags() {
-	flag.VisitAll(func(f *flag.Flag) {
-		if _, exclude := flagsToExcludeFromDump[f.Name]; !exclude {
-			fmt.Printf("%s = %s  # %s\n", f.Name, quoteValue(f.Value.String()), escapeUsage(f.Usage))
-		}
-	})
+// LoadedConfigPaths returns paths or urls of the config files the flags
+// were read from during the last successful config load, in the order
+// they were loaded.
+//
+// It returns nil if no config file has been loaded.
+func LoadedConfigPaths() []string {
+	return append([]string(nil), loadedConfigPaths...)
 }
 
-// escapeUsage escapes the usage string so it can be used as a comment in an ini file.
-func escapeUsage(s string) string {
-	// escape all the special characters that are not allowed. (tab, vertical tab, form feed, backspace, alert, backslash, double quote, superscript 2, superscript 3, superscript 1, superscript 0, superscript 4, superscript 5, superscript 6, superscript 7, superscript 8, superscript 9)
-	stringsToReplace := []string{"\t", "\v", "\f", "\b", "\a", "\\", "\"", "\u00B2", "\u00B3", "\u00B9", "\u2070", "\u2074", "\u2075", "\u2076", "\u2077", "\u2078", "\u2079"}
-	s = strings.Replace(s, "\n", "\n    # ", -1)
-	for _, str := range stringsToReplace {
-		s = strings.Replace(s, str, "", -1)
+// SetConfigCacheBusting enables or disables sending
+// "Cache-Control: no-cache" and "Pragma: no-cache" headers when config
+// files are loaded via http or https.
+//
+// These headers force caches such as CDNs in front of the config server
+// to revalidate the config. By default the headers are sent only on config
+// reloads, while the initial config load in Parse() may be served from cache.
+func SetConfigCacheBusting(enabled bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetConfigCacheBusting() must be called before Parse()")
 	}
+	cacheBusting = &enabled
+}
 
-	return s
+// SetSkipInvalidValues enables skipping config values, which cannot be
+// applied to their flags.
+//
+// By default a single invalid value fails the whole config load, so all
+// the flags retain their previous values. When skipping is enabled,
+// invalid values are logged and skipped, so the corresponding flags keep
+// their previous values, while valid values are applied.
+func SetSkipInvalidValues(skip bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetSkipInvalidValues() must be called before Parse()")
+	}
+	skipInvalidValues = skip
 }
 
-func quoteValue(v string) string {
-	if !strings.ContainsAny(v, "\n#;") && strings.TrimSpace(v) == v {
-		return v
+// SetTraceHook sets a function, which is called every time a flag value
+// is applied, with the source of the value, the flag name and the value.
+//
+// The source is one of "command-line", "config" (config values applied
+// during Parse()), "reload" (config values applied on config reloads)
+// or "rollback" (previous values restored after a failed config load).
+// The resulting chronological log shows how each flag obtained its value.
+func SetTraceHook(hook func(source, key, value string)) {
+	if parsed {
+		logger.Panicf("iniflags: SetTraceHook() must be called before Parse()")
 	}
-	v = strings.Replace(v, "\\", "\\\\", -1)
-	v = strings.Replace(v, "\n", "\\n", -1)
-	v = strings.Replace(v, "\"", "\\\"", -1)
-	return fmt.Sprintf("\"%s\"", v)
+	traceHook = hook
 }
 
-func unquoteValue(val string, lineNum int, configPath string) (string, string, bool) {
-	v := strings.TrimSpace(val)
-	if len(v) == 0 {
-		return "", "", true
+// SetPreprocessor sets a function for transforming config file contents
+// before they are parsed.
+//
+// The function is applied to every config file, including imported ones.
+// This allows converting non-standard config formats into the ini syntax
+// understood by iniflags. An error returned from the function aborts
+// config parsing.
+func SetPreprocessor(fn func(raw []byte) ([]byte, error)) {
+	if parsed {
+		logger.Panicf("iniflags: SetPreprocessor() must be called before Parse()")
 	}
-	if v[0] != '"' {
-		return removeTrailingComments(v), getTrailingComment(v), true
+	preprocessor = fn
+}
+
+// SetConfigReloadHook adds a function for transforming contents of config
+// files before they are parsed, e.g. for decrypting values or substituting
+// template variables.
+//
+// The function is called with the config path and the raw contents on the
+// initial load and on every reload, including imported files. Multiple hooks
+// may be added; they are applied in the order they were added, after the
+// function set via SetPreprocessor(). An error returned from a hook aborts
+// config loading, so the current flag values are kept on reload.
+// Must be called before Parse().
+func SetConfigReloadHook(fn func(path string, content []byte) ([]byte, error)) {
+	if parsed {
+		logger.Panicf("iniflags: SetConfigReloadHook() must be called before Parse()")
 	}
-	n := strings.LastIndex(v, "\"")
-	if n == -1 {
-		logger.Printf("iniflags: unclosed string found [%s] at line %d in config file [%s]", v, lineNum, configPath)
-		return "", "", false
+	configReloadHooks = append(configReloadHooks, fn)
+}
+
+// SetLineTransform sets a function for rewriting config lines
+// before they are parsed.
+//
+// The function is called for every line of every config file, including
+// imported ones, with the line stripped of the trailing newline, its number
+// and the config path. This allows supporting config dialects line by line,
+// e.g. expanding custom macros, while errors still refer to the original line
+// numbers. Unlike SetPreprocessor(), the function isn't applied to lines
+// of heredoc values. Must be called before Parse().
+func SetLineTransform(fn func(line string, lineNum int, path string) string) {
+	if parsed {
+		logger.Panicf("iniflags: SetLineTransform() must be called before Parse()")
 	}
-	v = v[1:n]
-	v = strings.Replace(v, "\\\"", "\"", -1)
-	v = strings.Replace(v, "\\n", "\n", -1)
-	v = strings.Replace(v, "\\\\", "\\", -1)
+	lineTransform = fn
+}
 
-	logger.Printf("iniflags: unquoted value [%s]", v)
+// TrimFlagValue registers a cutset, which is trimmed from both ends
+// of the value for the given flag read from config files before the value
+// is applied to the flag.
+//
+// Multiple cutsets may be registered for the same flag. They are applied
+// in registration order after the cutsets registered via TrimAllFlags().
+func TrimFlagValue(flagName string, cutset string) {
+	if fullName, ok := flagShorthands[flagName]; ok {
+		flagName = fullName
+	}
+	flagTrimCutsets[flagName] = append(flagTrimCutsets[flagName], cutset)
+}
 
-	//to get the comment remove the value from the original value and get the trailing comment
-	comment := getTrailingComment(strings.Replace(val, fmt.Sprintf("%q", v), "", 1))
-	logger.Printf("iniflags: comment [%s]", comment)
-	return v, comment, true
+// TrimAllFlags registers a cutset, which is trimmed from both ends
+// of values for all the string flags read from config files.
+//
+// See TrimFlagValue() for details.
+func TrimAllFlags(cutset string) {
+	allFlagsTrimCutsets = append(allFlagsTrimCutsets, cutset)
 }
 
-func removeTrailingComments(v string) string {
-	v = strings.Split(v, "#")[0]
-	v = strings.Split(v, ";")[0]
-	return strings.TrimSpace(v)
+// SetTOMLFlagsTable limits config parsing to keys under the table
+// with the given name, e.g. "flags" for the [flags] table.
+//
+// This allows keeping flag values in a single table of a TOML config
+// shared with the rest of the application. Lines outside the table,
+// including other tables, are ignored. Values in the table must use
+// the syntax supported by iniflags.
+func SetTOMLFlagsTable(name string) {
+	if parsed {
+		logger.Panicf("iniflags: SetTOMLFlagsTable() must be called before Parse()")
+	}
+	tomlFlagsTable = name
 }
 
-func getTrailingComment(v string) string {
-	if len(v) == 0 {
-		return ""
+// SetConfigSectionFilter limits config parsing to the given sections.
+//
+// Lines in other sections are ignored, while lines preceding the first
+// section header are always processed. This allows a single config file
+// to serve multiple services, e.g. SetConfigSectionFilter("service-a", "shared")
+// for a config with [service-a], [service-b] and [shared] sections.
+func SetConfigSectionFilter(sections ...string) {
+	if parsed {
+		logger.Panicf("iniflags: SetConfigSectionFilter() must be called before Parse()")
 	}
-	if v[0] == '"' {
-		return ""
+	configSectionFilter = make(map[string]bool, len(sections))
+	for _, section := range sections {
+		configSectionFilter[section] = true
 	}
-	s := strings.Split(v, "#")
-	if len(s) > 1 {
-		return s[1]
+}
+
+// SetLowercaseSections enables case-insensitive matching of section names.
+//
+// Section names in config files and names passed to SetConfigSectionFilter()
+// and SetTOMLFlagsTable() are lowercased before matching them against each
+// other, so [DB] and [db] are treated as the same section. Nothing else is
+// affected: section names aren't used in flag names, and dumped flags
+// aren't grouped by sections.
+func SetLowercaseSections(lowercase bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetLowercaseSections() must be called before Parse()")
 	}
-	s = strings.Split(v, ";")
-	if len(s) > 1 {
-		return s[1]
+	lowercaseSections = lowercase
+}
+
+// SetRejectControlChars enables rejecting config values containing
+// control chars such as "\x00" or "\t".
+//
+// Control chars usually appear in hand-edited config files as copy-paste
+// artifacts. Escape sequences such as "\n" in quoted values are still
+// allowed. Disabled by default, since tabs in quoted values are legitimate.
+func SetRejectControlChars(reject bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetRejectControlChars() must be called before Parse()")
 	}
-	return ""
+	rejectControlChars = reject
 }
 
-// SetConfigFile sets path to config file.
+// SetEnvironment enables conditional values in config files and sets
+// the environment name they are evaluated against.
 //
-// Call this function before Parse() if you need default path to config file
-// when -config command-line flag is not set.
-func SetConfigFile(path string) {
+// Conditional values have the form "selector?ifMatch:else". For example,
+// "logLevel = prod?warn:debug" sets logLevel to "warn" if the environment
+// is "prod" and to "debug" otherwise. Quoted values and values without
+// ':' after '?', such as "Ready?", are never treated as conditional.
+//
+// The environment name is usually obtained from an environment variable,
+// e.g. SetEnvironment(os.Getenv("APP_ENV")).
+func SetEnvironment(name string) {
 	if parsed {
-		logger.Panicf("iniflags: SetConfigFile() must be called before Parse()")
+		logger.Panicf("iniflags: SetEnvironment() must be called before Parse()")
 	}
-	*config = path
+	environment = name
 }
 
-func SetAllowMissingConfigFile(allowed bool) {
+// SetAssignmentSeparator sets the separator between keys and values
+// in config files.
+//
+// By default "=" is used. For example, SetAssignmentSeparator(":") allows
+// reading lines like "addr: :8080" from properties-style files. Keys are
+// split at the first occurrence of the separator, so "key:type" annotations
+// cannot be used with ":" separator.
+func SetAssignmentSeparator(sep string) {
 	if parsed {
-		panic("iniflags: SetAllowMissingConfigFile() must be called before Parse()")
+		logger.Panicf("iniflags: SetAssignmentSeparator() must be called before Parse()")
 	}
-	*allowMissingConfig = allowed
+	if sep == "" {
+		logger.Panicf("iniflags: assignment separator cannot be empty")
+	}
+	assignmentSeparator = sep
 }
 
-func SetAllowUnknownFlags(allowed bool) {
+// SetConfigPathMapper registers fn for translating local config file paths,
+// including paths from #import directives and file:// urls, before opening them.
+//
+// This is useful in containerized environments where config files are
+// mounted at paths different from the paths mentioned in configs.
+// For example, the following mapper prepends /mnt/host to absolute paths:
+//
+//	iniflags.SetConfigPathMapper(func(rawPath string) string {
+//		if filepath.IsAbs(rawPath) {
+//			return filepath.Join("/mnt/host", rawPath)
+//		}
+//		return rawPath
+//	})
+func SetConfigPathMapper(fn func(rawPath string) string) {
 	if parsed {
-		logger.Panicf("iniflags: SetAllowUnknownFlags() must be called before Parse()")
+		logger.Panicf("iniflags: SetConfigPathMapper() must be called before Parse()")
 	}
-	*allowUnknownFlags = allowed
+	configPathMapper = fn
 }
 
-func SetConfigUpdateInterval(interval time.Duration) {
+// SetHTTPUserAgent sets the User-Agent header sent when config files
+// are loaded via http or https.
+//
+// By default the header is "iniflags/VERSION (github.com/boomhut/iniflags)".
+func SetHTTPUserAgent(ua string) {
 	if parsed {
-		logger.Panicf("iniflags: SetConfigUpdateInterval() must be called before Parse()")
+		logger.Panicf("iniflags: SetHTTPUserAgent() must be called before Parse()")
 	}
-	*configUpdateInterval = interval
+	httpUserAgent = ua
 }
 
 // RegisterShorthand registers a shorthand for a flag.
@@ -674,6 +3075,9 @@ func RegisterShorthand(shorthand, fullName string) error {
 // RegisterCommandLineShorthand registers a shorthand that can be used on the command line.
 // The shorthand can be used both in config files and as a command-line flag.
 func RegisterCommandLineShorthand(shorthand, fullName string) error {
+	if err := checkShorthandMode(shorthand); err != nil {
+		return err
+	}
 	err := RegisterShorthand(shorthand, fullName)
 	if err != nil {
 		return err
@@ -683,6 +3087,139 @@ func RegisterCommandLineShorthand(shorthand, fullName string) error {
 	return nil
 }
 
+// ShorthandMode controls which shorthands may be used on the command line.
+type ShorthandMode int
+
+const (
+	// ShorthandMultiChar allows command-line shorthands of any length.
+	ShorthandMultiChar ShorthandMode = iota
+
+	// ShorthandSingleChar allows only single-char command-line shorthands
+	// such as -v according to the unix convention.
+	ShorthandSingleChar
+)
+
+// SetShorthandMode sets the mode for validating command-line shorthands.
+//
+// By default ShorthandMultiChar is used. Shorthands registered via
+// RegisterShorthand() are used only in config files, so they aren't
+// restricted by the mode.
+//
+// Command-line shorthands registered before the call are checked against
+// the new mode, so SetShorthandMode panics if any of them violates it.
+func SetShorthandMode(mode ShorthandMode) {
+	if parsed {
+		logger.Panicf("iniflags: SetShorthandMode() must be called before Parse()")
+	}
+	oldMode := shorthandMode
+	shorthandMode = mode
+	for shorthand := range commandLineShorthands {
+		if err := checkShorthandMode(shorthand); err != nil {
+			shorthandMode = oldMode
+			logger.Panicf("%s", err)
+		}
+	}
+	for _, shorthands := range commandShorthands {
+		for shorthand := range shorthands {
+			if err := checkShorthandMode(shorthand); err != nil {
+				shorthandMode = oldMode
+				logger.Panicf("%s", err)
+			}
+		}
+	}
+}
+
+func checkShorthandMode(shorthand string) error {
+	if shorthandMode == ShorthandSingleChar && utf8.RuneCountInString(shorthand) != 1 {
+		return fmt.Errorf("iniflags: command-line shorthand [%s] must be a single char; use RegisterShorthand() for config-only shorthands", shorthand)
+	}
+	return nil
+}
+
+// RegisterShorthandIn registers a shorthand for a flag, which is active
+// only when the given command is selected via SetCommand().
+//
+// This allows the same shorthand to mean different flags in different
+// commands of multi-command apps, e.g. -p may mean -port for "serve"
+// command and -path for "migrate" command. Like shorthands registered via
+// RegisterCommandLineShorthand(), the shorthand can be used both in config
+// files and as a command-line flag.
+func RegisterShorthandIn(command, shorthand, fullName string) error {
+	if parsed {
+		return fmt.Errorf("iniflags: RegisterShorthandIn() must be called before Parse()")
+	}
+	if err := checkShorthandMode(shorthand); err != nil {
+		return err
+	}
+	if flag.Lookup(fullName) == nil {
+		return fmt.Errorf("iniflags: cannot register shorthand [%s] for non-existing flag [%s] in command [%s]", shorthand, fullName, command)
+	}
+	if flag.Lookup(shorthand) != nil {
+		return fmt.Errorf("iniflags: shorthand [%s] already registered as a flag name", shorthand)
+	}
+	if existing, exists := flagShorthands[shorthand]; exists {
+		return fmt.Errorf("iniflags: shorthand [%s] already registered for flag [%s]", shorthand, existing)
+	}
+	shorthands := commandShorthands[command]
+	if shorthands == nil {
+		shorthands = make(map[string]string)
+		commandShorthands[command] = shorthands
+	}
+	if existing, exists := shorthands[shorthand]; exists {
+		return fmt.Errorf("iniflags: shorthand [%s] already registered for flag [%s] in command [%s]", shorthand, existing, command)
+	}
+	shorthands[shorthand] = fullName
+	return nil
+}
+
+// SetCommand activates shorthands registered via RegisterShorthandIn()
+// for the given command.
+//
+// It must be called before Parse().
+func SetCommand(command string) error {
+	if parsed {
+		return fmt.Errorf("iniflags: SetCommand() must be called before Parse()")
+	}
+	for shorthand := range commandShorthands[command] {
+		if existing, exists := flagShorthands[shorthand]; exists {
+			return fmt.Errorf("iniflags: shorthand [%s] for command [%s] conflicts with shorthand for flag [%s]", shorthand, command, existing)
+		}
+	}
+	for shorthand, fullName := range commandShorthands[command] {
+		flagShorthands[shorthand] = fullName
+		commandLineShorthands[shorthand] = true
+	}
+	return nil
+}
+
+// GetFlagUsage returns the usage string for the given flag extended
+// with the shorthands registered for it, e.g.
+// "Logging level: debug, info, warn, error (shorthand: -l)".
+//
+// The name may be either a full flag name or a registered shorthand.
+// An empty string is returned for unknown flags.
+func GetFlagUsage(name string) string {
+	f := lookupFlag(name)
+	if f == nil {
+		return ""
+	}
+	var shorts []string
+	for short, full := range flagShorthands {
+		if full == f.Name {
+			shorts = append(shorts, "-"+short)
+		}
+	}
+	switch len(shorts) {
+	case 0:
+		return f.Usage
+	case 1:
+		return fmt.Sprintf("%s (shorthand: %s)", f.Usage, shorts[0])
+	default:
+		sort.Strings(shorts)
+		return fmt.Sprintf("%s (shorthands: %s)", f.Usage, strings.Join(shorts, ", "))
+	}
+}
+
 // Logger is a slimmed-down version of the log.Logger interface, which only includes the methods we use.
 // This interface is accepted by SetLogger() to redirect log output to another destination.
 type Logger interface {
@@ -699,6 +3236,83 @@ func SetLogger(l Logger) {
 	logger = l
 }
 
+// LogLevel is the minimum level of messages written to the logger.
+type LogLevel int
+
+const (
+	// LogInfo writes informational messages, warnings and errors.
+	// This is the default level.
+	LogInfo LogLevel = iota
+
+	// LogDebug additionally writes debug messages such as parsed values.
+	LogDebug
+)
+
+// logLevel is the current LogLevel. It is accessed atomically, since
+// it may be changed by config reloads while messages are logged.
+var logLevel atomic.Int32
+
+// logLevelFlagName is the name of the flag controlling the log level.
+//
+// The flag may be set in the config file, so iniflags' tracing may be
+// enabled for subsequent reloads. Like other flags, the level from
+// the config file is applied only after the config is successfully applied.
+const logLevelFlagName = "iniflagsLogLevel"
+
+func init() {
+	flag.Var(logLevelValue{}, logLevelFlagName, "Log level for iniflags' own messages: info or debug.")
+}
+
+// SetLogLevel sets the log level.
+//
+// The log level may also be set via -iniflagsLogLevel flag,
+// either via command line or in the config file.
+func SetLogLevel(level LogLevel) {
+	logLevel.Store(int32(level))
+}
+
+func getLogLevel() LogLevel {
+	return LogLevel(logLevel.Load())
+}
+
+// String returns the name of the level.
+func (level LogLevel) String() string {
+	switch level {
+	case LogInfo:
+		return "info"
+	case LogDebug:
+		return "debug"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(level))
+	}
+}
+
+// logLevelValue is flag.Value for -iniflagsLogLevel flag.
+type logLevelValue struct{}
+
+func (v logLevelValue) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "info":
+		SetLogLevel(LogInfo)
+	case "debug":
+		SetLogLevel(LogDebug)
+	default:
+		return fmt.Errorf("unknown log level [%s]; supported levels are info and debug", s)
+	}
+	return nil
+}
+
+func (v logLevelValue) String() string {
+	return getLogLevel().String()
+}
+
+// debugf writes the message to the logger if LogDebug level is set.
+func debugf(format string, args ...interface{}) {
+	if getLogLevel() >= LogDebug {
+		logger.Printf(format, args...)
+	}
+}
+
 // customUsage displays the standard flag usage message along with registered shorthands
 func customUsage() {
 	// First call the original usage function
@@ -752,10 +3366,127 @@ func customUsage() {
 			}
 		}
 	}
+
+	writeConfigUsage(flag.CommandLine.Output())
+}
+
+// writeConfigUsage writes config paths to w, so users may verify
+// they edit the right config.
+//
+// Modification times of local config files and the last reload time
+// are written only after Parse().
+func writeConfigUsage(w io.Writer) {
+	paths := usageConfigPaths()
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nConfig files:\n")
+	for _, p := range paths {
+		fmt.Fprintf(w, "  %s", p)
+		if parsed {
+			if modTime, ok := configModTime(p); ok {
+				fmt.Fprintf(w, " (modified at %s)", modTime.Format(time.RFC3339))
+			}
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	if t := ReloadTime(); parsed && !t.IsZero() {
+		fmt.Fprintf(w, "Config reloaded at %s\n", t.Format(time.RFC3339))
+	}
+}
+
+// usageConfigPaths returns paths to configs, which are loaded
+// or are going to be loaded.
+func usageConfigPaths() []string {
+	if len(loadedConfigPaths) > 0 {
+		return loadedConfigPaths
+	}
+	var paths []string
+	for _, p := range resolveConfigPaths(*config, isSetOnCommandLine("config"), os.Getenv(configEnvVar), addedConfigFiles) {
+		if !strings.HasPrefix(p, "./") {
+			var err error
+			if p, err = resolvePath(getProgramPath(), p); err != nil {
+				continue
+			}
+		}
+		paths = append(paths, p)
+	}
+	if len(paths) == 0 {
+		paths = configURLs
+	}
+	return paths
+}
+
+// configModTime returns the modification time of the local config file
+// at the given path.
+func configModTime(configPath string) (time.Time, bool) {
+	if isFileURL(configPath) {
+		p, err := fileURLPath(configPath)
+		if err != nil {
+			return time.Time{}, false
+		}
+		configPath = p
+	} else if isURL(configPath) {
+		return time.Time{}, false
+	}
+	fi, err := os.Stat(configPath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
 }
 
 // ExcludeFlagFromDump excludes the flag from the output of the dumpflags command.
 // This is useful for sensitive flags that should not be exposed in the output.
 func ExcludeFlagFromDump(flagName string) {
-	flagsToExcludeFromDump[flagName] = true
+	AddExcludedFlagsFromDump(flagName)
+}
+
+// AddExcludedFlagsFromDump excludes the given flags from the output
+// of the dumpflags command.
+//
+// See ExcludeFlagFromDump() for details.
+func AddExcludedFlagsFromDump(flagNames ...string) {
+	for _, flagName := range flagNames {
+		flagsToExcludeFromDump[flagName] = true
+	}
+}
+
+// AllFlagNames returns sorted names of all the registered flags.
+func AllFlagNames() []string {
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	return names
+}
+
+// ExcludedFlagNames returns sorted names of flags excluded from dump
+// via ExcludeFlagFromDump().
+func ExcludedFlagNames() []string {
+	return sortedKeys(flagsToExcludeFromDump)
+}
+
+// SensitiveFlagNames returns sorted names of flags holding sensitive
+// values such as passwords, which are marked via MarkSecret()
+// or set via values with secret schemes such as keyring://.
+func SensitiveFlagNames() []string {
+	names := make(map[string]bool, len(sensitiveFlags))
+	for name := range sensitiveFlags {
+		names[name] = true
+	}
+	schemeSecretFlags.Range(func(name, _ interface{}) bool {
+		names[name.(string)] = true
+		return true
+	})
+	return sortedKeys(names)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -1,9 +1,24 @@
 package iniflags
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"testing/quick"
 	"time"
 )
 
@@ -18,6 +33,11 @@ func TestRemoveTrailingComments(t *testing.T) {
 	if clean != "v = v" {
 		t.Fatalf("Supposed to get 'v = v ', got '%s'", clean)
 	}
+	urlWithFragment := "http://host/#fragment;x # test_comment"
+	clean = removeTrailingComments(urlWithFragment)
+	if clean != "http://host/#fragment;x" {
+		t.Fatalf("Supposed to get 'http://host/#fragment;x', got '%s'", clean)
+	}
 }
 
 func TestGetTrailingComments(t *testing.T) {
@@ -61,6 +81,97 @@ func TestUnquoteValue(t *testing.T) {
 	}
 }
 
+func TestSetLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	oldLogger := logger
+	defer func() {
+		SetLogger(oldLogger)
+		SetLogLevel(LogInfo)
+	}()
+	SetLogger(log.New(&buf, "", 0))
+
+	content := `key = "foo"  # comment` + "\n"
+	if _, err := newConfigParser().parseReader("app.ini", strings.NewReader(content)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if buf.Len() > 0 {
+		t.Fatalf("Unexpected debug messages at info level: %q", buf.String())
+	}
+	SetLogLevel(LogDebug)
+	if _, err := newConfigParser().parseReader("app.ini", strings.NewReader(content)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(buf.String(), "unquoted value [foo]") {
+		t.Fatalf("Missing debug message at debug level: %q", buf.String())
+	}
+}
+
+func TestLogLevelFromConfig(t *testing.T) {
+	var buf bytes.Buffer
+	oldLogger := logger
+	defer func() {
+		SetLogger(oldLogger)
+		SetLogLevel(LogInfo)
+	}()
+	SetLogger(log.New(&buf, "", 0))
+	SetLogLevel(LogInfo)
+
+	content := `before = "1"
+iniflagsLogLevel = debug
+after = "2"
+`
+	args, err := newConfigParser().parseReader("loglevel.ini", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	if buf.Len() > 0 || getLogLevel() != LogInfo {
+		t.Fatalf("The log level mustn't be changed while reading config; got level %s and log %q", getLogLevel(), buf.String())
+	}
+	if len(args) != 3 || args[1].Key != "iniflagsLogLevel" || args[1].Value != "debug" {
+		t.Fatalf("Unexpected args parsed from config: %v", args)
+	}
+
+	// The level isn't applied if the config cannot be applied.
+	oldAllowUnknownFlags := *allowUnknownFlags
+	defer func() { *allowUnknownFlags = oldAllowUnknownFlags }()
+	*allowUnknownFlags = false
+	if _, _, _, ok := applyFlagArgs(args); ok {
+		t.Fatalf("Expecting error for unknown flags")
+	}
+	if getLogLevel() != LogInfo {
+		t.Fatalf("Unexpected log level after failed apply: %s. Expected %s", getLogLevel(), LogInfo)
+	}
+	if _, _, _, ok := applyFlagArgs(args[1:2]); !ok {
+		t.Fatalf("Cannot apply -iniflagsLogLevel from config")
+	}
+	if getLogLevel() != LogDebug {
+		t.Fatalf("Unexpected log level after apply: %s. Expected %s", getLogLevel(), LogDebug)
+	}
+
+	f := flag.Lookup("iniflagsLogLevel")
+	if f.Value.String() != "debug" {
+		t.Fatalf("Unexpected -iniflagsLogLevel=[%s]. Expected [debug]", f.Value.String())
+	}
+	if err := f.Value.Set("verbose"); err == nil {
+		t.Fatalf("Expecting error for unknown log level")
+	}
+	if !flagsToExcludeFromDump["iniflagsLogLevel"] {
+		t.Fatalf("-iniflagsLogLevel must be excluded from dump")
+	}
+}
+
+func TestReadIniFileRelativePath(t *testing.T) {
+	// Relative paths must be resolved against the current working directory
+	// instead of the directory with the executable.
+	args, ok := ReadIniFile("./test_bom.ini")
+	if !ok {
+		t.Fatalf("Cannot read config file by relative path")
+	}
+	if len(args) == 0 {
+		t.Fatalf("Expecting non-empty args")
+	}
+}
+
 func TestGetFlags(t *testing.T) {
 	parsed = false
 	Parse()
@@ -131,14 +242,85 @@ func TestGetArgsFromConfig(t *testing.T) {
 }
 
 func TestIsHTTP(t *testing.T) {
-	if !isHTTP("http://example.com") {
+	if !isURL("http://example.com") {
 		t.Fatalf("http://example.com should must be recognized as http path")
 	}
-	if !isHTTP("hTtpS://example.com") {
+	if !isURL("hTtpS://example.com") {
 		t.Fatalf("hTtpS://example.com should must be recognized as http path")
 	}
 }
 
+func TestIsFileURL(t *testing.T) {
+	if !isURL("file:///etc/app.ini") || !isFileURL("file:///etc/app.ini") {
+		t.Fatalf("file:///etc/app.ini must be recognized as file url")
+	}
+	if isFileURL("http://example.com") {
+		t.Fatalf("http://example.com mustn't be recognized as file url")
+	}
+	if isURL("/etc/app.ini") || isFileURL("/etc/app.ini") {
+		t.Fatalf("/etc/app.ini mustn't be recognized as url")
+	}
+}
+
+func TestFileURLPath(t *testing.T) {
+	f := func(fileURL, expected string) {
+		t.Helper()
+		p, err := fileURLPath(fileURL)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", fileURL, err)
+		}
+		if p != filepath.FromSlash(expected) {
+			t.Fatalf("Unexpected path for %q: %q. Expected %q", fileURL, p, filepath.FromSlash(expected))
+		}
+	}
+	f("file:///etc/myapp/config.ini", "/etc/myapp/config.ini")
+	f("file://localhost/etc/myapp/config.ini", "/etc/myapp/config.ini")
+	f("FILE:///etc/my%20app.ini", "/etc/my app.ini")
+
+	for _, fileURL := range []string{"file://example.com/etc/app.ini", "file://"} {
+		if _, err := fileURLPath(fileURL); err == nil {
+			t.Fatalf("Expecting error for %q", fileURL)
+		}
+	}
+}
+
+func TestLocalFilePath(t *testing.T) {
+	f := func(urlPath, goos, expected string) {
+		t.Helper()
+		if p := localFilePath(urlPath, goos); p != expected {
+			t.Fatalf("Unexpected path for %q on %s: %q. Expected %q", urlPath, goos, p, expected)
+		}
+	}
+	f("/etc/app.ini", "linux", "/etc/app.ini")
+	f("/C:/app/config.ini", "linux", "/C:/app/config.ini")
+	f("/C:/app/config.ini", "windows", `C:\app\config.ini`)
+	f("/d:/config.ini", "windows", `d:\config.ini`)
+	f("/app/config.ini", "windows", `\app\config.ini`)
+}
+
+func TestFileURLConfig(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Cannot obtain working directory: %s", err)
+	}
+	dir := filepath.ToSlash(cwd)
+	if !strings.HasPrefix(dir, "/") {
+		// Windows paths such as C:/foo are addressed as file:///C:/foo
+		dir = "/" + dir
+	}
+	fileURL := "file://" + dir + "/test_setconfigfile.ini"
+	if paths := splitConfigPaths(fileURL); len(paths) != 1 || paths[0] != fileURL {
+		t.Fatalf("Unexpected config paths %q. Expected [%q]", paths, fileURL)
+	}
+	args, ok := getArgsFromConfig(fileURL)
+	if !ok {
+		t.Fatalf("Cannot read config at %q", fileURL)
+	}
+	if len(args) != 1 || args[0].Key != "x" || args[0].Value != "foobar" {
+		t.Fatalf("Unexpected args read from %q: %+v", fileURL, args)
+	}
+}
+
 var x = flag.String("x", "baz", "for TestSetConfigFile")
 
 func TestSetConfigFile(t *testing.T) {
@@ -150,6 +332,168 @@ func TestSetConfigFile(t *testing.T) {
 	}
 }
 
+func TestResolveConfigPaths(t *testing.T) {
+	f := func(configValue string, setOnCommandLine bool, envValue string, added []string, expected string) {
+		t.Helper()
+		paths := resolveConfigPaths(configValue, setOnCommandLine, envValue, added)
+		if strings.Join(paths, ",") != expected {
+			t.Fatalf("Unexpected config paths for config=%q, setOnCommandLine=%v, env=%q, added=%q: %q. Expected %q",
+				configValue, setOnCommandLine, envValue, added, paths, expected)
+		}
+	}
+
+	// nothing set
+	f("", false, "", nil, "")
+
+	// SetConfigFile() only
+	f("default.ini", false, "", nil, "default.ini")
+
+	// AddConfigFile() only
+	f("", false, "", []string{"a.ini", "b.ini"}, "a.ini,b.ini")
+
+	// AddConfigFile() wins over SetConfigFile()
+	f("default.ini", false, "", []string{"a.ini", "b.ini"}, "a.ini,b.ini")
+
+	// -config wins over AddConfigFile()
+	f("cli.ini", true, "", []string{"a.ini", "b.ini"}, "cli.ini")
+
+	// -config wins over SetConfigFile(), since it overwrites the default
+	f("cli.ini", true, "", nil, "cli.ini")
+
+	// empty -config disables config files
+	f("", true, "", []string{"a.ini"}, "")
+
+	// INIFLAGS_CONFIG wins over AddConfigFile() and SetConfigFile()
+	f("default.ini", false, "env.ini", []string{"a.ini"}, "env.ini")
+	f("default.ini", false, "env.ini", nil, "env.ini")
+
+	// -config wins over INIFLAGS_CONFIG
+	f("cli.ini", true, "env.ini", nil, "cli.ini")
+	f("", true, "env.ini", nil, "")
+}
+
+func TestConfigEnvVar(t *testing.T) {
+	oldConfig := *config
+	oldX := *x
+	oldLoadedConfigPaths := loadedConfigPaths
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		loadedConfigPaths = oldLoadedConfigPaths
+	}()
+	*config = "./test_config2.ini"
+	*x = ""
+	t.Setenv(configEnvVar, "./test_setconfigfile.ini")
+
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse config set via %s", configEnvVar)
+	}
+	if *x != "foobar" {
+		t.Fatalf("Unexpected x=[%s]. Expected [foobar]", *x)
+	}
+	if LoadedConfigPath() != "./test_setconfigfile.ini" {
+		t.Fatalf("Unexpected loaded config path %q", LoadedConfigPath())
+	}
+}
+
+func TestSetExecutablePath(t *testing.T) {
+	defer func() { executablePath = "" }()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Cannot obtain executable path: %s", err)
+	}
+	if getProgramPath() != exe {
+		t.Fatalf("Unexpected program path %q. Expected %q", getProgramPath(), exe)
+	}
+
+	parsed = false
+	SetExecutablePath("/opt/app/bin/app")
+	if p, _ := combinePath(getProgramPath(), "config.ini"); p != "/opt/app/bin/config.ini" {
+		t.Fatalf("Unexpected config path %q. Expected %q", p, "/opt/app/bin/config.ini")
+	}
+	if p, _ := combinePath(getProgramPath(), "/etc/app.ini"); p != "/etc/app.ini" {
+		t.Fatalf("Unexpected config path %q. Expected %q", p, "/etc/app.ini")
+	}
+}
+
+func TestSetDefaultConfigContent(t *testing.T) {
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		defaultConfigContent = ""
+	}()
+	*config = ""
+	*x = ""
+	parsed = false
+	SetDefaultConfigContent("# baseline\nx = \"from default\"\n")
+
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse default config content")
+	}
+	if *x != "from default" {
+		t.Fatalf("Unexpected x=[%s]. Expected [from default]", *x)
+	}
+
+	// config file takes precedence over the default content
+	*config = "./test_setconfigfile.ini"
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse config file")
+	}
+	if *x != "foobar" {
+		t.Fatalf("Unexpected x=[%s]. Expected [foobar]", *x)
+	}
+
+	// the default content is used if the config file doesn't exist
+	*x = ""
+	*config = t.TempDir() + "/missing.ini"
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse default config content for missing config file")
+	}
+	if *x != "from default" {
+		t.Fatalf("Unexpected x=[%s]. Expected [from default]", *x)
+	}
+
+	*config = ""
+	defaultConfigContent = "no separator\n"
+	if _, ok := parseConfigFlags(); ok {
+		t.Fatalf("Invalid default config content must fail")
+	}
+}
+
+func TestAddConfigFile(t *testing.T) {
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		addedConfigFiles = nil
+	}()
+	*x = ""
+	parsed = false
+	SetConfigFile("./test_config2.ini")
+	AddConfigFile("./test_config2.ini")
+	AddConfigFile("./test_setconfigfile.ini")
+	*allowUnknownFlags = true
+	defer func() { *allowUnknownFlags = false }()
+
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse config files")
+	}
+	if *x != "foobar" {
+		t.Fatalf("Unexpected x=[%s]. Expected [foobar]", *x)
+	}
+	if LoadedConfigPath() != "./test_setconfigfile.ini" {
+		t.Fatalf("Unexpected loaded config path %q. Expected %q", LoadedConfigPath(), "./test_setconfigfile.ini")
+	}
+	expected := []string{"./test_config2.ini", "./test_setconfigfile.ini"}
+	if paths := LoadedConfigPaths(); !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Unexpected loaded config paths %q. Expected %q", paths, expected)
+	}
+}
+
 func TestSetAllowMissingConfigFile(t *testing.T) {
 	parsed = false
 	*allowMissingConfig = false
@@ -223,6 +567,20 @@ func TestCombinePathHTTP(t *testing.T) {
 	}
 }
 
+func TestCombinePathInvalid(t *testing.T) {
+	// Test combinePath rejects paths with null bytes and too long paths.
+	basePath := "/etc/app/config.ini"
+	if _, ok := combinePath(basePath, "sub\x00/conf.ini"); ok {
+		t.Fatalf("combinePath must fail for path with null byte")
+	}
+	if _, ok := combinePath(basePath, strings.Repeat("a", maxConfigPathLen+1)); ok {
+		t.Fatalf("combinePath must fail for too long path")
+	}
+	if _, err := newConfigParser().openConfigFile("/etc/app\x00.ini"); err == nil {
+		t.Fatalf("openConfigFile must fail for path with null byte")
+	}
+}
+
 func TestStripBOM(t *testing.T) {
 	// Test that stripBOM correctly strips a BOM if present.
 	input := "\ufeffHello"
@@ -238,3 +596,2084 @@ func TestDumpFlags(t *testing.T) {
 	Parse()
 	dumpFlags()
 }
+
+func TestHTTPUserAgent(t *testing.T) {
+	var ua string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua = r.Header.Get("User-Agent")
+		fmt.Fprintf(w, "x = foobar\n")
+	}))
+	defer ts.Close()
+
+	if !strings.HasPrefix(httpUserAgent, "iniflags/") {
+		t.Fatalf("Unexpected default user-agent %q", httpUserAgent)
+	}
+
+	parsed = false
+	oldUA := httpUserAgent
+	defer func() { httpUserAgent = oldUA }()
+	SetHTTPUserAgent("test-agent/1.0")
+
+	*unsecure = true
+	defer func() { *unsecure = false }()
+	if _, ok := getArgsFromConfig(ts.URL + "/config.ini"); !ok {
+		t.Fatalf("Cannot read config via http")
+	}
+	if ua != "test-agent/1.0" {
+		t.Fatalf("Unexpected user-agent %q. Expected %q", ua, "test-agent/1.0")
+	}
+}
+
+func TestUnencryptedHTTPWarning(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "x = foobar\n")
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	oldLogger := logger
+	defer SetLogger(oldLogger)
+	SetLogger(log.New(&buf, "", 0))
+
+	*unsecure = true
+	defer func() { *unsecure = false }()
+
+	configURL := ts.URL + "/config.ini"
+	for i := 0; i < 3; i++ {
+		if _, ok := getArgsFromConfig(configURL); !ok {
+			t.Fatalf("Cannot read config via http")
+		}
+	}
+	if n := strings.Count(buf.String(), "over unencrypted http"); n != 1 {
+		t.Fatalf("Unexpected number of warnings: %d. Expected 1; log: %q", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), configURL) || !strings.Contains(buf.String(), "passwords or API keys") {
+		t.Fatalf("Unexpected warning: %q", buf.String())
+	}
+
+	*unsecure = false
+	if _, err := newConfigParser().openConfigFile(configURL); err == nil {
+		t.Fatalf("Expecting error when loading config via http without -unsecure")
+	}
+}
+
+func TestSetConfigURLs(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "x = mirror\n")
+	}))
+	defer up.Close()
+
+	*unsecure = true
+	defer func() { *unsecure = false }()
+
+	oldAllowMissingConfig := *allowMissingConfig
+	*allowMissingConfig = false
+	defer func() { *allowMissingConfig = oldAllowMissingConfig }()
+
+	urls := []string{down.URL + "/config.ini", up.URL + "/config.ini"}
+	args, configPath, ok := getArgsFromConfigURLs(urls)
+	if !ok {
+		t.Fatalf("Cannot load config from %v", urls)
+	}
+	if configPath != urls[1] {
+		t.Fatalf("Unexpected config url %q. Expected %q", configPath, urls[1])
+	}
+	if len(args) != 1 || args[0].Value != "mirror" {
+		t.Fatalf("Unexpected args %v read from %q", args, configPath)
+	}
+
+	if _, _, ok = getArgsFromConfigURLs(urls[:1]); ok {
+		t.Fatalf("Loading config from unavailable url must fail")
+	}
+}
+
+func TestSetPreprocessor(t *testing.T) {
+	parsed = false
+	defer func() { preprocessor = nil }()
+	SetPreprocessor(func(raw []byte) ([]byte, error) {
+		return bytes.Replace(raw, []byte(": "), []byte("= "), -1), nil
+	})
+	args, err := newConfigParser().parseReader("colon.ini", strings.NewReader("foo: bar\n"))
+	if err != nil {
+		t.Fatalf("Cannot read preprocessed config: %s", err)
+	}
+	if len(args) != 1 || args[0].Key != "foo" || args[0].Value != "bar" {
+		t.Fatalf("Unexpected args parsed from preprocessed config: %v", args)
+	}
+
+	SetPreprocessor(func(raw []byte) ([]byte, error) {
+		return nil, fmt.Errorf("unsupported header")
+	})
+	if _, err = newConfigParser().parseReader("colon.ini", strings.NewReader("foo: bar\n")); err == nil {
+		t.Fatalf("Preprocessor error must abort config parsing")
+	}
+}
+
+func TestSetConfigReloadHook(t *testing.T) {
+	parsed = false
+	defer func() {
+		configReloadHooks = nil
+		preprocessor = nil
+	}()
+	SetPreprocessor(func(raw []byte) ([]byte, error) {
+		return bytes.Replace(raw, []byte("---\n"), nil, 1), nil
+	})
+	SetConfigReloadHook(func(path string, content []byte) ([]byte, error) {
+		if path != "app.ini" {
+			return nil, fmt.Errorf("unexpected path %q", path)
+		}
+		return bytes.Replace(content, []byte("{{name}}"), []byte("foo"), -1), nil
+	})
+	SetConfigReloadHook(func(path string, content []byte) ([]byte, error) {
+		return bytes.ToUpper(content), nil
+	})
+	args, err := newConfigParser().parseReader("app.ini", strings.NewReader("---\nkey = {{name}}\n"))
+	if err != nil {
+		t.Fatalf("Cannot read transformed config: %s", err)
+	}
+	if len(args) != 1 || args[0].Key != "KEY" || args[0].Value != "FOO" {
+		t.Fatalf("Unexpected args parsed from transformed config: %+v", args)
+	}
+
+	SetConfigReloadHook(func(path string, content []byte) ([]byte, error) {
+		return nil, fmt.Errorf("cannot decrypt")
+	})
+	if _, err := newConfigParser().parseReader("app.ini", strings.NewReader("key = value\n")); err == nil {
+		t.Fatalf("Hook error must abort config parsing")
+	}
+}
+
+func TestSetLineTransform(t *testing.T) {
+	parsed = false
+	defer func() { lineTransform = nil }()
+	var lineNums []int
+	SetLineTransform(func(line string, lineNum int, path string) string {
+		if path != "vendor.ini" {
+			t.Fatalf("Unexpected path %q", path)
+		}
+		lineNums = append(lineNums, lineNum)
+		return strings.TrimPrefix(line, "vendor.")
+	})
+	content := "vendor.foo = bar\r\n\nbaz = 1\nvendor.qux\n"
+	_, err := newConfigParser().parseReader("vendor.ini", strings.NewReader(content))
+	if err == nil || !strings.Contains(err.Error(), "cannot split line 4") {
+		t.Fatalf("Unexpected error: %v. Expected error for the transformed line 4", err)
+	}
+	args, err := newConfigParser().parseReader("vendor.ini", strings.NewReader(content[:len(content)-11]))
+	if err != nil {
+		t.Fatalf("Cannot read transformed config: %s", err)
+	}
+	if len(args) != 2 || args[0].Key != "foo" || args[0].Value != "bar" || args[1].Key != "baz" || args[1].LineNum != 3 {
+		t.Fatalf("Unexpected args parsed from transformed config: %+v", args)
+	}
+	if fmt.Sprint(lineNums) != "[1 2 3 4 1 2 3]" {
+		t.Fatalf("Unexpected line numbers passed to the transform: %v", lineNums)
+	}
+}
+
+func TestRegisterShorthandIn(t *testing.T) {
+	flag.String("servePort", "8080", "flag for TestRegisterShorthandIn")
+	flag.String("migratePath", "", "flag for TestRegisterShorthandIn")
+	defer func() {
+		commandShorthands = make(map[string]map[string]string)
+		delete(flagShorthands, "p")
+		delete(commandLineShorthands, "p")
+	}()
+
+	parsed = false
+	if err := RegisterShorthandIn("serve", "p", "servePort"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := RegisterShorthandIn("migrate", "p", "migratePath"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := RegisterShorthandIn("serve", "p", "migratePath"); err == nil {
+		t.Fatalf("Expecting error for duplicate shorthand in the same command")
+	}
+	if _, ok := flagShorthands["p"]; ok {
+		t.Fatalf("Command shorthands mustn't be active before SetCommand()")
+	}
+	if err := SetCommand("migrate"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if flagShorthands["p"] != "migratePath" || !commandLineShorthands["p"] {
+		t.Fatalf("Unexpected shorthand -p for migrate command: [%s]", flagShorthands["p"])
+	}
+}
+
+func TestSetShorthandMode(t *testing.T) {
+	defer func() {
+		shorthandMode = ShorthandMultiChar
+		delete(flagShorthands, "xs")
+		delete(commandLineShorthands, "xs")
+		delete(flagShorthands, "xm")
+		delete(commandLineShorthands, "xm")
+	}()
+
+	parsed = false
+	SetShorthandMode(ShorthandSingleChar)
+	if err := RegisterCommandLineShorthand("xs", "x"); err == nil {
+		t.Fatalf("Expecting error for multi-char command-line shorthand")
+	}
+	if err := RegisterShorthand("xs", "x"); err != nil {
+		t.Fatalf("Unexpected error for config-only shorthand: %s", err)
+	}
+
+	// command-line shorthands registered before the mode switch are checked
+	SetShorthandMode(ShorthandMultiChar)
+	if err := RegisterCommandLineShorthand("xm", "x"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expecting panic for multi-char command-line shorthand registered before SetShorthandMode()")
+		}
+		if shorthandMode != ShorthandMultiChar {
+			t.Fatalf("Shorthand mode mustn't change on panic")
+		}
+	}()
+	SetShorthandMode(ShorthandSingleChar)
+}
+
+func TestAllFlagNames(t *testing.T) {
+	names := AllFlagNames()
+	if !sort.StringsAreSorted(names) {
+		t.Fatalf("Flag names must be sorted: %v", names)
+	}
+	found := false
+	for _, name := range names {
+		if name == "x" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Flag names must contain x: %v", names)
+	}
+
+	defer delete(flagsToExcludeFromDump, "x")
+	ExcludeFlagFromDump("x")
+	excluded := strings.Join(ExcludedFlagNames(), ",")
+	if !strings.Contains(excluded, "config,") || !strings.HasSuffix(excluded, ",x") {
+		t.Fatalf("Unexpected excluded flag names: %s", excluded)
+	}
+}
+
+func TestAddExcludedFlagsFromDump(t *testing.T) {
+	defer func() {
+		delete(flagsToExcludeFromDump, "excludedA")
+		delete(flagsToExcludeFromDump, "excludedB")
+	}()
+	AddExcludedFlagsFromDump("excludedA", "excludedB")
+	if !flagsToExcludeFromDump["excludedA"] || !flagsToExcludeFromDump["excludedB"] {
+		t.Fatalf("All the given flags must be excluded from dump: %v", ExcludedFlagNames())
+	}
+}
+
+func TestExpandCommandLineShorthands(t *testing.T) {
+	defer func() {
+		delete(flagShorthands, "xx")
+		delete(commandLineShorthands, "xx")
+	}()
+	parsed = false
+	if err := RegisterCommandLineShorthand("xx", "x"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	args := expandCommandLineShorthands([]string{"app", "-xx=a", "--xx", "b", "--xx=c", "--", "-xx"})
+	expected := "app -x=a --x b --x=c -- -xx"
+	if strings.Join(args, " ") != expected {
+		t.Fatalf("Unexpected expanded args %q. Expected %q", strings.Join(args, " "), expected)
+	}
+}
+
+func TestGetFlagUsage(t *testing.T) {
+	flag.String("usageTestFlag", "", "Usage test flag")
+	defer func() {
+		delete(flagShorthands, "utf")
+		delete(flagShorthands, "u")
+	}()
+
+	parsed = false
+	expected := "Usage test flag"
+	if usage := GetFlagUsage("usageTestFlag"); usage != expected {
+		t.Fatalf("Unexpected usage %q. Expected %q", usage, expected)
+	}
+	if err := RegisterShorthand("utf", "usageTestFlag"); err != nil {
+		t.Fatalf("Cannot register shorthand: %s", err)
+	}
+	expected = "Usage test flag (shorthand: -utf)"
+	if usage := GetFlagUsage("usageTestFlag"); usage != expected {
+		t.Fatalf("Unexpected usage %q. Expected %q", usage, expected)
+	}
+	if err := RegisterShorthand("u", "usageTestFlag"); err != nil {
+		t.Fatalf("Cannot register shorthand: %s", err)
+	}
+	expected = "Usage test flag (shorthands: -u, -utf)"
+	if usage := GetFlagUsage("utf"); usage != expected {
+		t.Fatalf("Unexpected usage %q. Expected %q", usage, expected)
+	}
+	if usage := GetFlagUsage("nonExistingFlag"); usage != "" {
+		t.Fatalf("Unexpected usage %q for unknown flag", usage)
+	}
+}
+
+func TestWriteConfigUsage(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = foobar\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Cannot set config modification time: %s", err)
+	}
+	oldConfig := *config
+	oldLoadedConfigPaths := loadedConfigPaths
+	oldReloadTime := ReloadTime()
+	defer func() {
+		*config = oldConfig
+		loadedConfigPaths = oldLoadedConfigPaths
+		setReloadTime(oldReloadTime)
+		parsed = true
+	}()
+	*config = path
+	loadedConfigPaths = nil
+
+	// the config isn't loaded yet
+	parsed = false
+	var bb bytes.Buffer
+	writeConfigUsage(&bb)
+	expected := "\nConfig files:\n  " + path + "\n"
+	if bb.String() != expected {
+		t.Fatalf("Unexpected usage %q. Expected %q", bb.String(), expected)
+	}
+
+	// the config is loaded
+	parsed = true
+	loadedConfigPaths = []string{path}
+	reloadTime := time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)
+	setReloadTime(reloadTime)
+	bb.Reset()
+	writeConfigUsage(&bb)
+	expected = "\nConfig files:\n  " + path + " (modified at " + modTime.Local().Format(time.RFC3339) + ")\n" +
+		"Config reloaded at " + reloadTime.Format(time.RFC3339) + "\n"
+	if bb.String() != expected {
+		t.Fatalf("Unexpected usage %q. Expected %q", bb.String(), expected)
+	}
+
+	// no configs
+	*config = ""
+	loadedConfigPaths = nil
+	bb.Reset()
+	writeConfigUsage(&bb)
+	if bb.Len() > 0 {
+		t.Fatalf("Unexpected usage %q. Expected empty output", bb.String())
+	}
+}
+
+func TestTrimFlagValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("s", "", "")
+	fs.Int("n", 0, "")
+	defer func() {
+		flagTrimCutsets = make(map[string][]string)
+		allFlagsTrimCutsets = nil
+	}()
+
+	TrimAllFlags("'")
+	TrimFlagValue("s", "<>")
+	TrimFlagValue("s", " ")
+	if v := trimFlagValue(fs.Lookup("s"), "'< foo >'"); v != "foo" {
+		t.Fatalf("Unexpected trimmed value %q. Expected %q", v, "foo")
+	}
+	if v := trimFlagValue(fs.Lookup("n"), "'12'"); v != "'12'" {
+		t.Fatalf("TrimAllFlags() must not trim non-string flags, got %q", v)
+	}
+}
+
+func TestSetTOMLFlagsTable(t *testing.T) {
+	parsed = false
+	defer func() { tomlFlagsTable = "" }()
+	SetTOMLFlagsTable("flags")
+
+	config := `title = "app"
+
+[server]
+ports = [
+  8000,
+  8001,
+]
+
+[flags]
+logLevel = "debug"
+workers = 4
+
+[[plugins]]
+name = "foo"
+`
+	args, err := newConfigParser().parseReader("app.toml", strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Cannot read TOML config: %s", err)
+	}
+	if len(args) != 2 {
+		t.Fatalf("Unexpected args parsed from TOML config: %v", args)
+	}
+	if args[0].Key != "logLevel" || args[0].Value != "debug" || args[1].Key != "workers" || args[1].Value != "4" {
+		t.Fatalf("Unexpected args parsed from TOML config: %v", args)
+	}
+}
+
+func TestSetConfigSectionFilter(t *testing.T) {
+	parsed = false
+	defer func() { configSectionFilter = nil }()
+	SetConfigSectionFilter("service-a", "shared")
+
+	content := `global = 1
+[service-a]
+a = 2
+[service-b]
+b = 3
+[shared]
+shared = 4
+`
+	args, err := newConfigParser().parseReader("services.ini", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	var keys []string
+	for _, arg := range args {
+		keys = append(keys, arg.Key)
+	}
+	if strings.Join(keys, ",") != "global,a,shared" {
+		t.Fatalf("Unexpected keys parsed from config: %v. Expected [global a shared]", keys)
+	}
+
+	// heredoc bodies in skipped sections mustn't switch the section
+	content = `[service-b]
+script = <<END
+[service-a]
+b = 3
+END
+b = 4
+[service-a]
+a = <<END
+[shared]
+END
+`
+	args, err = newConfigParser().parseReader("services.ini", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	if len(args) != 1 || args[0].Key != "a" || args[0].Value != "[shared]" || args[0].LineNum != 8 {
+		t.Fatalf("Unexpected args parsed from config: %+v. Expected a single arg a=[shared] at line 8", args)
+	}
+}
+
+func TestSetLowercaseSections(t *testing.T) {
+	parsed = false
+	defer func() {
+		configSectionFilter = nil
+		lowercaseSections = false
+	}()
+	SetConfigSectionFilter("DB")
+	SetLowercaseSections(true)
+
+	content := `[db]
+path = /var/db
+[Cache]
+size = 10
+[DB]
+timeout = 5s
+`
+	args, err := newConfigParser().parseReader("sections.ini", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	var keys []string
+	for _, arg := range args {
+		keys = append(keys, arg.Key)
+	}
+	if strings.Join(keys, ",") != "path,timeout" {
+		t.Fatalf("Unexpected keys parsed from config: %v. Expected [path timeout]", keys)
+	}
+}
+
+func TestSetEnvironment(t *testing.T) {
+	parsed = false
+	defer func() { environment = "" }()
+	SetEnvironment("prod")
+
+	content := `logLevel = prod?warn:debug
+workers = staging?8:4
+url = http://host/path?a=b:c
+quoted = "prod?warn:debug"
+`
+	args, err := newConfigParser().parseReader("app.ini", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	expected := []string{"warn", "4", "http://host/path?a=b:c", "prod?warn:debug"}
+	if len(args) != len(expected) {
+		t.Fatalf("Unexpected args parsed from config: %v", args)
+	}
+	for i, arg := range args {
+		if arg.Value != expected[i] {
+			t.Fatalf("Unexpected value for [%s]: [%s]. Expected [%s]", arg.Key, arg.Value, expected[i])
+		}
+	}
+
+	// values without ':' after '?' aren't conditional
+	args, err = newConfigParser().parseReader("app.ini", strings.NewReader("msg = Ready?\nlogLevel = prod?warn\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error for values without ':': %s", err)
+	}
+	if len(args) != 2 || args[0].Value != "Ready?" || args[1].Value != "prod?warn" {
+		t.Fatalf("Unexpected args parsed from config: %v", args)
+	}
+}
+
+func TestSetRejectControlChars(t *testing.T) {
+	content := "a = \"foo\\nbar\"\nb = \"foo\tbar\"\n"
+	if _, err := newConfigParser().parseReader("app.ini", strings.NewReader(content)); err != nil {
+		t.Fatalf("Unexpected error with control chars allowed: %s", err)
+	}
+
+	parsed = false
+	defer func() { rejectControlChars = false }()
+	SetRejectControlChars(true)
+	_, err := newConfigParser().parseReader("app.ini", strings.NewReader(content))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Expecting error at line 2 for the tab char; got %v", err)
+	}
+
+	// control chars in trailing comments are allowed
+	args, err := newConfigParser().parseReader("app.ini", strings.NewReader("a = foo\t# comment\nb = \"bar\"\t# comment\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error for the tab char before comment: %s", err)
+	}
+	if len(args) != 2 || args[0].Value != "foo" || args[1].Value != "bar" {
+		t.Fatalf("Unexpected args parsed from config: %v", args)
+	}
+
+	// heredoc bodies are checked
+	_, err = newConfigParser().parseReader("app.ini", strings.NewReader("a = foo\nb = <<END\nline1\nfoo\tbar\nEND\n"))
+	if err == nil || !strings.Contains(err.Error(), "heredoc started at line 2") {
+		t.Fatalf("Expecting error for the tab char in heredoc started at line 2; got %v", err)
+	}
+	args, err = newConfigParser().parseReader("app.ini", strings.NewReader("b = <<END\nline1\nline2\nEND\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error for heredoc without control chars: %s", err)
+	}
+	if len(args) != 1 || args[0].Value != "line1\nline2" {
+		t.Fatalf("Unexpected args parsed from config: %v", args)
+	}
+}
+
+func TestValuesWithSeparator(t *testing.T) {
+	content := `dsn = user=pass@host/db
+query = http://host/?a=b&c=d  # comment
+compact=a=b
+list{,} = a=b
+list{,} = c=d
+[db]
+quoted = "host=x password=y#z" ; comment
+conn = host=db port=5432 sslmode=disable
+`
+	args, err := newConfigParser().parseReader("dsn.ini", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	expected := []FlagArg{
+		{Key: "dsn", Value: "user=pass@host/db"},
+		{Key: "query", Value: "http://host/?a=b&c=d"},
+		{Key: "compact", Value: "a=b"},
+		{Key: "list", Value: "a=b,c=d"},
+		{Key: "quoted", Value: "host=x password=y#z"},
+		{Key: "conn", Value: "host=db port=5432 sslmode=disable"},
+	}
+	if len(args) != len(expected) {
+		t.Fatalf("Unexpected number of args: %d. Expected %d: %v", len(args), len(expected), args)
+	}
+	for i, arg := range args {
+		if arg.Key != expected[i].Key || arg.Value != expected[i].Value {
+			t.Fatalf("Unexpected arg #%d: %s=[%s]. Expected %s=[%s]", i, arg.Key, arg.Value, expected[i].Key, expected[i].Value)
+		}
+	}
+
+	// Verify the value survives the full reload.
+	path := t.TempDir() + "/dsn.ini"
+	if err := os.WriteFile(path, []byte("x = user=pass@host/db?sslmode=disable # comment\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldX := *x
+	defer func() { *x = oldX }()
+	parsed = true
+	if _, err := ReloadFile(path); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *x != "user=pass@host/db?sslmode=disable" {
+		t.Fatalf("Unexpected x=[%s]. Expected [user=pass@host/db?sslmode=disable]", *x)
+	}
+}
+
+func TestDecodeConfigLine(t *testing.T) {
+	f := func(line, expectedKey, expectedValue, expectedComment string) {
+		t.Helper()
+		key, value, comment, err := DecodeConfigLine(line)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", line, err)
+		}
+		if key != expectedKey || value != expectedValue || comment != expectedComment {
+			t.Fatalf("Unexpected result for %q: key=%q, value=%q, comment=%q. Expected key=%q, value=%q, comment=%q",
+				line, key, value, comment, expectedKey, expectedValue, expectedComment)
+		}
+	}
+	f("", "", "", "")
+	f("  # just a comment", "", "", "just a comment")
+	f("; another comment", "", "", "another comment")
+	f("addr = :8080", "addr", ":8080", "")
+	f("  addr=:8080  # listen addr", "addr", ":8080", "listen addr")
+	f(`name = "foo # bar\n\"baz\""  ; comment`, "name", "foo # bar\n\"baz\"", "comment")
+	f("url = http://host/#fragment", "url", "http://host/#fragment", "")
+	f("dsn = user=pass@host/db", "dsn", "user=pass@host/db", "")
+	f("list{,} = a", "list{,}", "a", "")
+	f("timeout:duration = 5s", "timeout:duration", "5s", "")
+	f("empty =", "empty", "", "")
+
+	for _, line := range []string{"no separator", "= value", `key = "unclosed`} {
+		if _, _, _, err := DecodeConfigLine(line); err == nil {
+			t.Fatalf("Expecting error for %q", line)
+		}
+	}
+}
+
+func TestUnquoteConfigValue(t *testing.T) {
+	f := func(raw, expected string) {
+		t.Helper()
+		v, err := UnquoteConfigValue(raw)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", raw, err)
+		}
+		if v != expected {
+			t.Fatalf("Unexpected value for %q: %q. Expected %q", raw, v, expected)
+		}
+	}
+	f("", "")
+	f(" plain value ", "plain value")
+	f("value # comment", "value")
+	f(`"quoted # value" ; comment`, "quoted # value")
+	f(`"a\\b\nc\"d"`, "a\\b\nc\"d")
+	f(`"C:\path"`, `C:\path`)
+
+	// round trip with quoting used by -dumpflags
+	for _, v := range []string{"foo", " spaces ", "a#b;c", "line1\nline2", `back\slash "quotes"`, "<<EOF"} {
+		f(quoteValue(v), v)
+	}
+
+	if _, err := UnquoteConfigValue(`"hunter2`); err == nil || strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("Expecting error without the value for unclosed quote; got %v", err)
+	}
+}
+
+func TestConfigValueRoundTrip(t *testing.T) {
+	roundTrip := func(v string) bool {
+		unquoted, err := UnquoteConfigValue(QuoteConfigValue(v))
+		return err == nil && unquoted == v
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Random strings rarely contain chars special for the config syntax,
+	// so generate strings from these chars.
+	const specialChars = " \t\n\r\"\\#;?<:=an"
+	cfg := &quick.Config{
+		MaxCount: 10000,
+		Values: func(values []reflect.Value, r *rand.Rand) {
+			b := make([]byte, r.Intn(10))
+			for i := range b {
+				b[i] = specialChars[r.Intn(len(specialChars))]
+			}
+			values[0] = reflect.ValueOf(string(b))
+		},
+	}
+	if err := quick.Check(roundTrip, cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHeredoc(t *testing.T) {
+	content := `cert = <<PEM
+-----BEGIN CERTIFICATE-----
+  MIIB # not a comment
+-----END CERTIFICATE-----
+PEM
+next = value
+`
+	args, err := newConfigParser().parseReader("app.ini", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	if len(args) != 2 {
+		t.Fatalf("Unexpected args parsed from config: %v", args)
+	}
+	expected := "-----BEGIN CERTIFICATE-----\n  MIIB # not a comment\n-----END CERTIFICATE-----"
+	if args[0].Key != "cert" || args[0].Value != expected {
+		t.Fatalf("Unexpected heredoc value %q. Expected %q", args[0].Value, expected)
+	}
+	if args[1].Key != "next" || args[1].LineNum != 6 {
+		t.Fatalf("Unexpected arg after heredoc: %+v", args[1])
+	}
+
+	_, err = newConfigParser().parseReader("app.ini", strings.NewReader("cert = <<PEM\nfoo\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("Expecting error for unterminated heredoc; got %v", err)
+	}
+}
+
+func TestSetConfigPathMapper(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/etc/app", 0755); err != nil {
+		t.Fatalf("Cannot create dir: %s", err)
+	}
+	if err := os.WriteFile(dir+"/etc/app/base.ini", []byte("x = base\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	if err := os.WriteFile(dir+"/etc/app/main.ini", []byte("#import \"/etc/app/base.ini\"\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+
+	parsed = false
+	defer func() { configPathMapper = nil }()
+	SetConfigPathMapper(func(rawPath string) string {
+		return dir + rawPath
+	})
+	args, err := newConfigParser().parseFile("/etc/app/main.ini")
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	if len(args) != 1 || args[0].Value != "base" {
+		t.Fatalf("Unexpected args parsed from config: %v", args)
+	}
+
+	// file urls are mapped as well
+	args, err = newConfigParser().parseFile("file:///etc/app/main.ini")
+	if err != nil {
+		t.Fatalf("Cannot read config via file url: %s", err)
+	}
+	if len(args) != 1 || args[0].Value != "base" {
+		t.Fatalf("Unexpected args parsed from config via file url: %v", args)
+	}
+}
+
+func TestSetAssignmentSeparator(t *testing.T) {
+	parsed = false
+	defer func() { assignmentSeparator = "=" }()
+	SetAssignmentSeparator(":")
+
+	content := `addr: :8080  # comment
+list{,}: a
+list{,}: b
+`
+	args, err := newConfigParser().parseReader("app.properties", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	if len(args) != 2 || args[0].Key != "addr" || args[0].Value != ":8080" || args[1].Key != "list" || args[1].Value != "a,b" {
+		t.Fatalf("Unexpected args parsed from config: %+v", args)
+	}
+}
+
+func TestSplitConfigPaths(t *testing.T) {
+	if os.PathListSeparator != ':' {
+		t.Skip("the test is for ':' path list separator")
+	}
+	f := func(s string, expected ...string) {
+		t.Helper()
+		paths := splitConfigPaths(s)
+		if strings.Join(paths, "|") != strings.Join(expected, "|") {
+			t.Fatalf("Unexpected paths %q for %q. Expected %q", paths, s, expected)
+		}
+	}
+	f("/etc/app/base.ini:http://example.com:8080/app.ini::./local.ini:https://example.com/x.ini",
+		"/etc/app/base.ini", "http://example.com:8080/app.ini", "./local.ini", "https://example.com/x.ini")
+	f("http://example.com:8080:./local.ini", "http://example.com:8080", "./local.ini")
+	f("http://example.com:./local.ini", "http://example.com", "./local.ini")
+	f("https://[::1]:8443/app.ini:file:///etc/app.ini", "https://[::1]:8443/app.ini", "file:///etc/app.ini")
+	f("./http:/app.ini", "./http", "/app.ini")
+	f("")
+}
+
+func TestMultipleConfigPaths(t *testing.T) {
+	dir := t.TempDir()
+	base := dir + "/base.ini"
+	local := dir + "/local.ini"
+	if err := os.WriteFile(base, []byte("x = base\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	if err := os.WriteFile(local, []byte("x = local\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+
+	oldConfig := *config
+	defer func() { *config = oldConfig }()
+	*config = base + string(os.PathListSeparator) + local
+
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse multiple configs")
+	}
+	if *x != "local" {
+		t.Fatalf("Unexpected x=[%s]. Expected [local]", *x)
+	}
+	if LoadedConfigPath() != local {
+		t.Fatalf("Unexpected loaded config path %q. Expected %q", LoadedConfigPath(), local)
+	}
+	if paths := LoadedConfigPaths(); !reflect.DeepEqual(paths, []string{base, local}) {
+		t.Fatalf("Unexpected loaded config paths %q. Expected %q", paths, []string{base, local})
+	}
+}
+
+func TestOnConfigReloadFailure(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	oldAllowUnknownFlags := *allowUnknownFlags
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		*allowUnknownFlags = oldAllowUnknownFlags
+		reloadFailureHandlers = nil
+		pendingCallbacks = make(map[string]string)
+	}()
+	*config = path
+	*allowUnknownFlags = false
+
+	var errs []error
+	var generations []int
+	OnConfigReloadFailure(func(err error, generation int) {
+		errs = append(errs, err)
+		generations = append(generations, generation)
+
+		// handlers may set flags
+		if err := SetFlagValueWithoutCallback("x", "fromFailureHandler"); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	parsed = true
+	TriggerReload()
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected failures for valid config: %v", errs)
+	}
+
+	if err := os.WriteFile(path, []byte("x = after\nreloadFailureUnknown = 1\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	generation := Generation
+	done := make(chan struct{})
+	go func() {
+		TriggerReload()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Deadlock when setting flags from reload failure handlers")
+	}
+	if *x != "fromFailureHandler" {
+		t.Fatalf("Unexpected x=[%s]. Expected [fromFailureHandler]", *x)
+	}
+	if len(errs) != 1 || generations[0] != generation {
+		t.Fatalf("Unexpected failures %v for generations %v. Expected a single failure for generation %d", errs, generations, generation)
+	}
+	var pe *ParseError
+	if !errors.As(errs[0], &pe) {
+		t.Fatalf("Error %v must wrap *ParseError", errs[0])
+	}
+	if pe.FilePath != path || pe.LineNum != 2 || pe.Key != "reloadFailureUnknown" {
+		t.Fatalf("Unexpected ParseError %+v", pe)
+	}
+}
+
+func TestParseTimeReloadTime(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	defer func() { *config = oldConfig }()
+
+	startTime := time.Now()
+	parsed = false
+	Parse()
+	parseTime := ParseTime()
+	if parseTime.Before(startTime) || parseTime.After(time.Now()) {
+		t.Fatalf("Unexpected ParseTime()=%s. Expected time after %s", parseTime, startTime)
+	}
+	if !ReloadTime().Equal(parseTime) {
+		t.Fatalf("Unexpected ReloadTime()=%s after Parse(). Expected %s", ReloadTime(), parseTime)
+	}
+
+	*config = path
+	TriggerReload()
+	reloadTime := ReloadTime()
+	if !reloadTime.After(parseTime) {
+		t.Fatalf("Unexpected ReloadTime()=%s after reload. Expected time after %s", reloadTime, parseTime)
+	}
+	if !ParseTime().Equal(parseTime) {
+		t.Fatalf("ParseTime() mustn't be updated after reload; got %s; want %s", ParseTime(), parseTime)
+	}
+
+	// failed reload mustn't update ReloadTime()
+	if err := os.WriteFile(path, []byte("x = \"unclosed\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	TriggerReload()
+	if !ReloadTime().Equal(reloadTime) {
+		t.Fatalf("ReloadTime() mustn't be updated after failed reload; got %s; want %s", ReloadTime(), reloadTime)
+	}
+	if *x != "before" {
+		t.Fatalf("Unexpected x=[%s]. Expected [before]", *x)
+	}
+}
+
+func TestTriggerReload(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	defer func() {
+		*config = oldConfig
+		configReloadCallbacks = nil
+	}()
+	*config = path
+
+	var triggers []ReloadTrigger
+	OnConfigReload(func(trigger ReloadTrigger) {
+		triggers = append(triggers, trigger)
+	})
+
+	parsed = true
+	TriggerReload()
+	if *x != "before" {
+		t.Fatalf("Unexpected x=[%s]. Expected [before]", *x)
+	}
+	if err := os.WriteFile(path, []byte("x = after\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	generation := Generation
+	reloadTime := ReloadTime()
+	TriggerReload()
+	if !ReloadTime().After(reloadTime) {
+		t.Fatalf("ReloadTime() must be updated after reload")
+	}
+	if *x != "after" {
+		t.Fatalf("Unexpected x=[%s]. Expected [after]", *x)
+	}
+	if Generation != generation+1 {
+		t.Fatalf("Unexpected Generation=%d. Expected %d", Generation, generation+1)
+	}
+	if len(triggers) != 2 || triggers[0] != TriggerManual || triggers[1] != TriggerManual {
+		t.Fatalf("Unexpected triggers %v", triggers)
+	}
+	if changed := ChangedSince(generation); len(changed) != 1 || changed[0] != "x" {
+		t.Fatalf("Unexpected flags changed since generation %d: %v. Expected [x]", generation, changed)
+	}
+	if changed := ChangedSince(Generation); len(changed) != 0 {
+		t.Fatalf("Unexpected flags changed since the current generation: %v", changed)
+	}
+}
+
+func TestOnFlagChangeEvent(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		delete(flagEventCallbacks, "x")
+	}()
+	*config = path
+
+	parsed = true
+	TriggerReload()
+
+	var events []FlagChangeEvent
+	OnFlagChangeEvent("x", func(event FlagChangeEvent) {
+		events = append(events, event)
+	})
+	if err := os.WriteFile(path, []byte("x = after\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	TriggerReload()
+	expected := FlagChangeEvent{
+		Name:       "x",
+		OldValue:   "before",
+		NewValue:   "after",
+		Trigger:    TriggerManual,
+		Generation: Generation,
+	}
+	if len(events) != 1 || events[0] != expected {
+		t.Fatalf("Unexpected events %+v. Expected [%+v]", events, expected)
+	}
+
+	if err := SetFlagValueWithoutCallback("x", "set"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	FirePendingCallbacks()
+	expected = FlagChangeEvent{
+		Name:       "x",
+		OldValue:   "after",
+		NewValue:   "set",
+		Trigger:    TriggerFlagSet,
+		Generation: Generation,
+	}
+	if len(events) != 2 || events[1] != expected {
+		t.Fatalf("Unexpected events %+v. Expected the last event %+v", events, expected)
+	}
+}
+
+func TestCallbacksMayReloadConfig(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		delete(flagChangeCallbacks, "x")
+		configReloadCallbacks = nil
+	}()
+	*config = path
+
+	parsed = true
+	TriggerReload()
+
+	// Callbacks are called without holding the reload lock,
+	// so they may reload the config or set flags.
+	flagCallbackCalls := 0
+	OnFlagChange("x", func() {
+		flagCallbackCalls++
+		if flagCallbackCalls == 1 {
+			TriggerReload()
+			if err := SetFlagValueWithoutCallback("x", "fromCallback"); err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}
+	})
+	reloadCallbackCalls := 0
+	OnConfigReload(func(trigger ReloadTrigger) {
+		reloadCallbackCalls++
+		if reloadCallbackCalls == 1 {
+			if _, err := ReloadFile(path); err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}
+	})
+	if err := os.WriteFile(path, []byte("x = after\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		TriggerReload()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Deadlock when reloading config from callbacks")
+	}
+	if *x != "fromCallback" {
+		t.Fatalf("Unexpected x=[%s]. Expected [fromCallback]", *x)
+	}
+	if reloadCallbackCalls != 2 {
+		t.Fatalf("Unexpected number of OnConfigReload calls: %d. Expected 2", reloadCallbackCalls)
+	}
+}
+
+func TestReloadFile(t *testing.T) {
+	path := t.TempDir() + "/overlay.ini"
+	if err := os.WriteFile(path, []byte("x = overlay\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldX := *x
+	defer func() {
+		*x = oldX
+		delete(flagChangeCallbacks, "x")
+	}()
+	*x = "base"
+
+	callbackCalls := 0
+	OnFlagChange("x", func() {
+		callbackCalls++
+	})
+
+	parsed = true
+	changed, err := ReloadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(changed) != 1 || changed[0] != "x" {
+		t.Fatalf("Unexpected changed flags %v. Expected [x]", changed)
+	}
+	if *x != "overlay" {
+		t.Fatalf("Unexpected x=[%s]. Expected [overlay]", *x)
+	}
+	if callbackCalls != 1 {
+		t.Fatalf("Unexpected number of callback calls: %d. Expected 1", callbackCalls)
+	}
+
+	changed, err = ReloadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(changed) != 0 {
+		t.Fatalf("Unexpected changed flags %v on the second reload", changed)
+	}
+
+	if _, err := ReloadFile(path + ".missing"); err == nil {
+		t.Fatalf("Expecting error for missing file")
+	}
+}
+
+func TestParseConfigFileOnly(t *testing.T) {
+	path := t.TempDir() + "/extra.ini"
+	if err := os.WriteFile(path, []byte("x = extra\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldX := *x
+	defer func() {
+		*x = oldX
+		delete(flagChangeCallbacks, "x")
+		delete(flagEventCallbacks, "x")
+	}()
+	*x = "base"
+
+	callbackCalls := 0
+	OnFlagChange("x", func() {
+		callbackCalls++
+	})
+	var events []FlagChangeEvent
+	OnFlagChangeEvent("x", func(event FlagChangeEvent) {
+		events = append(events, event)
+	})
+
+	parsed = true
+	generation := Generation
+	if err := ParseConfigFileOnly(path); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *x != "extra" {
+		t.Fatalf("Unexpected x=[%s]. Expected [extra]", *x)
+	}
+	if Generation != generation+1 {
+		t.Fatalf("Unexpected Generation=%d. Expected %d", Generation, generation+1)
+	}
+	if callbackCalls != 1 {
+		t.Fatalf("Unexpected number of callback calls: %d. Expected 1", callbackCalls)
+	}
+	if len(events) != 1 || events[0].OldValue != "base" || events[0].NewValue != "extra" || events[0].Trigger != TriggerManual {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+
+	if err := ParseConfigFileOnly(path + ".missing"); err == nil {
+		t.Fatalf("Expecting error for missing file")
+	}
+	if *x != "extra" {
+		t.Fatalf("Unexpected x=[%s] after failed call. Expected [extra]", *x)
+	}
+}
+
+func TestReloadConfigMapSymlinkSwap(t *testing.T) {
+	// Simulate Kubernetes ConfigMap volume layout:
+	//
+	//   config.ini -> ..data/config.ini
+	//   ..data -> ..v1
+	dir := t.TempDir()
+	writeVersion := func(version, value string) {
+		if err := os.Mkdir(filepath.Join(dir, version), 0755); err != nil {
+			t.Fatalf("Cannot create dir: %s", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, version, "config.ini"), []byte("x = "+value+"\n"), 0644); err != nil {
+			t.Fatalf("Cannot write config: %s", err)
+		}
+	}
+	swapData := func(version string) {
+		// Swap the symlink atomically via rename like kubelet does.
+		tmpLink := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(version, tmpLink); err != nil {
+			t.Fatalf("Cannot create symlink: %s", err)
+		}
+		if err := os.Rename(tmpLink, filepath.Join(dir, "..data")); err != nil {
+			t.Fatalf("Cannot swap symlink: %s", err)
+		}
+	}
+	writeVersion("..v1", "v1")
+	swapData("..v1")
+	path := filepath.Join(dir, "config.ini")
+	if err := os.Symlink(filepath.Join("..data", "config.ini"), path); err != nil {
+		t.Fatalf("Cannot create symlink: %s", err)
+	}
+
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+	}()
+	*config = path
+
+	parsed = true
+	TriggerReload()
+	if *x != "v1" {
+		t.Fatalf("Unexpected x=[%s]. Expected [v1]", *x)
+	}
+	writeVersion("..v2", "v2")
+	swapData("..v2")
+	if err := os.RemoveAll(filepath.Join(dir, "..v1")); err != nil {
+		t.Fatalf("Cannot remove old version: %s", err)
+	}
+	TriggerReload()
+	if *x != "v2" {
+		t.Fatalf("Unexpected x=[%s] after symlink swap. Expected [v2]", *x)
+	}
+}
+
+func TestSetFlagValueWithoutCallback(t *testing.T) {
+	oldX := *x
+	defer func() {
+		*x = oldX
+		delete(flagChangeCallbacks, "x")
+	}()
+	*x = "initial"
+
+	callbackCalls := 0
+	OnFlagChange("x", func() {
+		callbackCalls++
+	})
+
+	parsed = true
+	if err := SetFlagValueWithoutCallback("x", "foo"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := SetFlagValueWithoutCallback("x", "bar"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *x != "bar" {
+		t.Fatalf("Unexpected x=[%s]. Expected [bar]", *x)
+	}
+	if callbackCalls != 0 {
+		t.Fatalf("Callbacks mustn't be called before FirePendingCallbacks()")
+	}
+	FirePendingCallbacks()
+	if callbackCalls != 1 {
+		t.Fatalf("Unexpected number of callback calls: %d. Expected 1", callbackCalls)
+	}
+	FirePendingCallbacks()
+	if callbackCalls != 1 {
+		t.Fatalf("Pending callbacks mustn't be called twice")
+	}
+	if err := SetFlagValueWithoutCallback("unknownFlag", "foo"); err == nil {
+		t.Fatalf("Expecting error for unknown flag")
+	}
+}
+
+func TestSetReloadable(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		SetReloadable("x", true)
+	}()
+	*config = path
+
+	parsed = true
+	TriggerReload()
+	SetReloadable("x", false)
+	if IsReloadable("x") {
+		t.Fatalf("x must be non-reloadable")
+	}
+	for _, name := range ReloadableFlags() {
+		if name == "x" {
+			t.Fatalf("ReloadableFlags() mustn't contain x")
+		}
+	}
+	if err := os.WriteFile(path, []byte("x = after\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	TriggerReload()
+	if *x != "before" {
+		t.Fatalf("Non-reloadable flag x mustn't be changed on reload; got [%s]", *x)
+	}
+}
+
+func TestSetReloadExcludeFunc(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		SetReloadExcludeFunc(nil)
+	}()
+	*config = path
+
+	parsed = true
+	TriggerReload()
+	SetReloadExcludeFunc(func(name string) bool {
+		return strings.HasPrefix(name, "x")
+	})
+	if IsReloadable("x") {
+		t.Fatalf("x must be non-reloadable")
+	}
+	if !IsReloadable("config") {
+		t.Fatalf("config must be reloadable")
+	}
+	if err := os.WriteFile(path, []byte("x = after\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	TriggerReload()
+	if *x != "before" {
+		t.Fatalf("Excluded flag x mustn't be changed on reload; got [%s]", *x)
+	}
+
+	SetReloadExcludeFunc(nil)
+	TriggerReload()
+	if *x != "after" {
+		t.Fatalf("Flag x must be changed on reload after removing exclude func; got [%s]", *x)
+	}
+}
+
+func TestSetCallbackOnConfirm(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = confirmed\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		delete(flagChangeCallbacks, "x")
+		delete(flagEventCallbacks, "x")
+		SetCallbackOnConfirm("x", false)
+		SetReloadable("x", true)
+	}()
+	*config = path
+
+	parsed = true
+	TriggerReload()
+
+	calls := 0
+	var events []FlagChangeEvent
+	parsed = false
+	OnFlagChange("x", func() { calls++ })
+	OnFlagChangeEvent("x", func(event FlagChangeEvent) {
+		events = append(events, event)
+	})
+	parsed = true
+	TriggerReload()
+	if calls != 0 || len(events) != 0 {
+		t.Fatalf("Callbacks mustn't be called for unchanged flag; got %d calls and events %+v", calls, events)
+	}
+
+	SetCallbackOnConfirm("x", true)
+	TriggerReload()
+	if calls != 1 {
+		t.Fatalf("Callback must be called for confirmed flag; got %d calls", calls)
+	}
+	if len(events) != 1 || !events[0].Confirmed || events[0].OldValue != "confirmed" || events[0].NewValue != "confirmed" || events[0].Trigger != TriggerManual {
+		t.Fatalf("Unexpected events for confirmed flag: %+v", events)
+	}
+
+	// non-reloadable flags with ignored config values aren't confirmed
+	if err := os.WriteFile(path, []byte("x = ignored\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	SetReloadable("x", false)
+	TriggerReload()
+	if calls != 1 || len(events) != 1 {
+		t.Fatalf("Callbacks mustn't be called for flag with ignored value; got %d calls and events %+v", calls, events)
+	}
+	if *x != "confirmed" {
+		t.Fatalf("Unexpected x=[%s]. Expected [confirmed]", *x)
+	}
+	SetReloadable("x", true)
+
+	if err := os.WriteFile(path, []byte("\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	TriggerReload()
+	if calls != 1 {
+		t.Fatalf("Callback mustn't be called for flag missing in config; got %d calls", calls)
+	}
+}
+
+func TestGenerationChanges(t *testing.T) {
+	oldGenerationChans := generationChans
+	defer func() { generationChans = oldGenerationChans }()
+
+	ch := GenerationChanges()
+	select {
+	case g := <-ch:
+		t.Fatalf("Unexpected notification for generation %d", g)
+	default:
+	}
+
+	incGeneration()
+	incGeneration()
+	select {
+	case g := <-ch:
+		if g != Generation {
+			t.Fatalf("Unexpected generation %d. Expected %d", g, Generation)
+		}
+	default:
+		t.Fatalf("Expecting notification after Generation change")
+	}
+	select {
+	case g := <-ch:
+		t.Fatalf("Missed notifications must be coalesced; got extra notification for generation %d", g)
+	default:
+	}
+}
+
+func TestSetReloadMutex(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		flagsMutex = nil
+	}()
+	*config = path
+
+	var mu sync.RWMutex
+	parsed = false
+	SetReloadMutex(&mu)
+	parsed = true
+	TriggerReload()
+	if err := os.WriteFile(path, []byte("x = after\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+
+	mu.RLock()
+	doneCh := make(chan struct{})
+	go func() {
+		TriggerReload()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+		t.Fatalf("Reload must wait for the read lock to be released")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if *x != "before" {
+		t.Fatalf("Flag x mustn't be changed while the read lock is held; got [%s]", *x)
+	}
+	mu.RUnlock()
+	<-doneCh
+	if *x != "after" {
+		t.Fatalf("Unexpected x=[%s]. Expected [after]", *x)
+	}
+}
+
+func TestOnFlagChangeShorthand(t *testing.T) {
+	defer func() {
+		delete(flagShorthands, "xc")
+		delete(flagChangeCallbacks, "x")
+	}()
+	parsed = false
+	if err := RegisterShorthand("xc", "x"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	OnFlagChange("xc", func() {})
+	if len(flagChangeCallbacks["x"]) != 1 {
+		t.Fatalf("Callback for shorthand must be registered for the full flag name")
+	}
+	if _, ok := flagChangeCallbacks["xc"]; ok {
+		t.Fatalf("Callback mustn't be registered for shorthand")
+	}
+}
+
+func TestSetConfigUpdateIntervalAfterParse(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = before\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	defer func() {
+		SetConfigUpdateInterval(0)
+		*config = oldConfig
+		*x = oldX
+	}()
+	*config = path
+
+	parsed = true
+	SetConfigUpdateInterval(10 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("x = after\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for lockedFlagValue("x") != "after" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Config hasn't been reloaded after setting update interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	SetConfigUpdateInterval(0)
+	for {
+		updaterMu.Lock()
+		running := updaterRunning
+		updaterMu.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("configUpdater hasn't been stopped after setting zero interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// lockedFlagValue returns the flag value under reloadMu.
+func lockedFlagValue(name string) string {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return flag.Lookup(name).Value.String()
+}
+
+func TestSetConfigCacheBusting(t *testing.T) {
+	var cacheControl string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cacheControl = r.Header.Get("Cache-Control")
+	}))
+	defer ts.Close()
+	*unsecure = true
+	defer func() {
+		*unsecure = false
+		cacheBusting = nil
+	}()
+
+	if _, ok := getArgsFromConfig(ts.URL); !ok || cacheControl != "" {
+		t.Fatalf("Unexpected Cache-Control %q for initial config load", cacheControl)
+	}
+	reloading = true
+	_, ok := getArgsFromConfig(ts.URL)
+	reloading = false
+	if !ok || cacheControl != "no-cache" {
+		t.Fatalf("Unexpected Cache-Control %q for config reload", cacheControl)
+	}
+
+	parsed = false
+	SetConfigCacheBusting(true)
+	if _, ok := getArgsFromConfig(ts.URL); !ok || cacheControl != "no-cache" {
+		t.Fatalf("Unexpected Cache-Control %q for initial config load with enabled cache busting", cacheControl)
+	}
+}
+
+// accumulatingValue appends items passed to Set like many custom list flags.
+type accumulatingValue []string
+
+func (v *accumulatingValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *accumulatingValue) Set(s string) error {
+	if s == "bad" {
+		return fmt.Errorf("invalid item [%s]", s)
+	}
+	*v = append(*v, s)
+	return nil
+}
+
+func TestSetSkipInvalidValues(t *testing.T) {
+	n := flag.Int("skipInvalidTestInt", 1, "int flag for TestSetSkipInvalidValues")
+	acc := &accumulatingValue{"a"}
+	flag.Var(acc, "skipInvalidTestAccumulating", "accumulating flag for TestSetSkipInvalidValues")
+	// Reset the value, so it round-trips in TestDumpFlagsRoundTrip.
+	defer func() { *acc = nil }()
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("skipInvalidTestInt = foo\nskipInvalidTestAccumulating = bad\nx = valid\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	defer func() {
+		*config = oldConfig
+		skipInvalidValues = false
+	}()
+	*config = path
+
+	if _, ok := parseConfigFlags(); ok {
+		t.Fatalf("Invalid value must fail config parsing")
+	}
+
+	parsed = false
+	SetSkipInvalidValues(true)
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Invalid value must be skipped")
+	}
+	if *n != 1 {
+		t.Fatalf("Unexpected skipInvalidTestInt=%d. Expected 1", *n)
+	}
+	if acc.String() != "a" {
+		t.Fatalf("Unexpected skipInvalidTestAccumulating=[%s]. Expected [a]", acc)
+	}
+	if *x != "valid" {
+		t.Fatalf("Unexpected x=[%s]. Expected [valid]", *x)
+	}
+}
+
+func TestReadIniFileWithOptions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/app.ini":     {Data: []byte("x = foo\n#import \"base.ini\"\n")},
+		"etc/base.ini":    {Data: []byte("#import \"common.ini\"\n")},
+		"etc/common.ini":  {Data: []byte("x = common\n")},
+		"etc/unknown.ini": {Data: []byte("nonExistingFlag = 1\n")},
+	}
+	args, err := ReadIniFileWithOptions("/etc/app.ini", ReadOptions{FS: fsys})
+	if err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+	if len(args) != 2 || args[0].Value != "foo" || args[1].Value != "common" || args[1].FilePath != "/etc/common.ini" {
+		t.Fatalf("Unexpected args %+v", args)
+	}
+
+	// file urls are opened via FS
+	args, err = ReadIniFileWithOptions("file:///etc/app.ini", ReadOptions{FS: fsys})
+	if err != nil {
+		t.Fatalf("Cannot read config via file url: %s", err)
+	}
+	if len(args) != 2 || args[1].Value != "common" || args[1].FilePath != "file:///etc/common.ini" {
+		t.Fatalf("Unexpected args read via file url %+v", args)
+	}
+
+	if _, err = ReadIniFileWithOptions("/etc/app.ini", ReadOptions{FS: fsys, MaxDepth: 1}); err == nil {
+		t.Fatalf("Exceeding MaxDepth must fail")
+	}
+	if _, err = ReadIniFileWithOptions("/etc/unknown.ini", ReadOptions{FS: fsys}); err == nil {
+		t.Fatalf("Unknown flag must fail")
+	}
+	if _, err = ReadIniFileWithOptions("/etc/unknown.ini", ReadOptions{FS: fsys, AllowUnknown: true}); err != nil {
+		t.Fatalf("Unknown flag must be allowed: %s", err)
+	}
+	if _, err = ReadIniFileWithOptions("/etc/missing.ini", ReadOptions{FS: fsys}); err == nil {
+		t.Fatalf("Missing config must fail")
+	}
+	if _, err = ReadIniFileWithOptions("/etc/missing.ini", ReadOptions{FS: fsys, AllowMissing: true}); err != nil {
+		t.Fatalf("Missing config must be allowed: %s", err)
+	}
+}
+
+func TestSetTraceHook(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = traced\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	defer func() {
+		*config = oldConfig
+		traceHook = nil
+	}()
+	*config = path
+
+	var trace []string
+	parsed = false
+	SetTraceHook(func(source, key, value string) {
+		trace = append(trace, source+":"+key+"="+value)
+	})
+	*x = "untraced"
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse config")
+	}
+	if len(trace) != 1 || trace[0] != "config:x=traced" {
+		t.Fatalf("Unexpected trace %q", trace)
+	}
+}
+
+func TestDumpFlagsWithChanges(t *testing.T) {
+	fs := flag.CommandLine
+	fs.String("dumpChangedTestFlag", "default", "flag for TestDumpFlagsWithChanges")
+	fs.String("dumpUnchangedTestFlag", "default", "flag for TestDumpFlagsWithChanges")
+	if err := fs.Lookup("dumpChangedTestFlag").Value.Set("custom"); err != nil {
+		t.Fatalf("Cannot set flag: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := DumpFlagsWithChanges(&buf); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	dump := buf.String()
+	expected := "# (changed from default: default)\ndumpChangedTestFlag = custom  # flag for TestDumpFlagsWithChanges\n"
+	if !strings.Contains(dump, expected) {
+		t.Fatalf("Dump doesn't contain %q:\n%s", expected, dump)
+	}
+	unchanged := "\ndumpUnchangedTestFlag = default  # flag for TestDumpFlagsWithChanges\n"
+	if !strings.Contains(dump, unchanged) || strings.Contains(dump, "default)"+unchanged) {
+		t.Fatalf("Unexpected dump for unchanged flag:\n%s", dump)
+	}
+}
+
+func TestDumpChangedFlags(t *testing.T) {
+	fs := flag.CommandLine
+	fs.String("dumpOnlyChangedTestFlag", "default", "flag for TestDumpChangedFlags")
+	fs.String("dumpOnlyUnchangedTestFlag", "default", "flag for TestDumpChangedFlags")
+	if err := fs.Lookup("dumpOnlyChangedTestFlag").Value.Set("custom"); err != nil {
+		t.Fatalf("Cannot set flag: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := DumpChangedFlags(&buf); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	dump := buf.String()
+	if !strings.Contains(dump, "dumpOnlyChangedTestFlag = custom  # flag for TestDumpChangedFlags\n") {
+		t.Fatalf("Dump must contain the changed flag:\n%s", dump)
+	}
+	if strings.Contains(dump, "dumpOnlyUnchangedTestFlag") {
+		t.Fatalf("Dump mustn't contain the unchanged flag:\n%s", dump)
+	}
+	if strings.Contains(dump, "changed from default") {
+		t.Fatalf("Dump mustn't contain change markers:\n%s", dump)
+	}
+}
+
+type rangeValue struct {
+	min, max int
+}
+
+func (v *rangeValue) String() string { return fmt.Sprintf("%d-%d", v.min, v.max) }
+func (v *rangeValue) Set(s string) error {
+	_, err := fmt.Sscanf(s, "%d-%d", &v.min, &v.max)
+	return err
+}
+func (v *rangeValue) Validate() error {
+	if v.min > v.max {
+		return fmt.Errorf("min=%d exceeds max=%d", v.min, v.max)
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	v := &rangeValue{min: 1, max: 10}
+	flag.Var(v, "validatorRange", "flag for TestValidator")
+
+	if _, _, _, ok := applyFlagArgs([]FlagArg{{Key: "validatorRange", Value: "2-5"}}); !ok {
+		t.Fatalf("Cannot apply valid value")
+	}
+	if v.min != 2 || v.max != 5 {
+		t.Fatalf("Unexpected value %s. Expected 2-5", v)
+	}
+	if _, _, _, ok := applyFlagArgs([]FlagArg{{Key: "validatorRange", Value: "7-3"}}); ok {
+		t.Fatalf("Expecting validation error")
+	}
+	if v.min != 2 || v.max != 5 {
+		t.Fatalf("Invalid value must be rolled back; got %s", v)
+	}
+}
+
+type dumperValue struct {
+	bytes int
+}
+
+func (v *dumperValue) String() string     { return fmt.Sprintf("%d bytes", v.bytes) }
+func (v *dumperValue) DumpString() string { return fmt.Sprintf("%d", v.bytes) }
+func (v *dumperValue) Set(s string) error {
+	_, err := fmt.Sscanf(s, "%d", &v.bytes)
+	return err
+}
+
+func TestDumpFlagsDumper(t *testing.T) {
+	flag.Var(&dumperValue{bytes: 1024}, "dumperTestFlag", "flag for TestDumpFlagsDumper")
+	isolateDump(t, "dumperTestFlag")
+
+	var buf bytes.Buffer
+	if err := DumpFlagsToWriter(&buf); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	expected := "dumperTestFlag = 1024  # flag for TestDumpFlagsDumper\n"
+	if buf.String() != expected {
+		t.Fatalf("Unexpected dump %q. Expected %q", buf.String(), expected)
+	}
+}
+
+// isolateDump restricts dumps to the given flags and resets the dump
+// settings changed by other tests until the end of t.
+func isolateDump(t *testing.T, names ...string) {
+	t.Helper()
+	oldFilter, oldCommentChar := dumpFilter, dumpCommentChar
+	t.Cleanup(func() {
+		dumpFilter, dumpCommentChar = oldFilter, oldCommentChar
+	})
+	dumpCommentChar = '#'
+	SetDumpFilter(func(name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestSetDumpCommentChar(t *testing.T) {
+	flag.String("dumpCommentCharFlag", "", "line1\nline2")
+	isolateDump(t, "dumpCommentCharFlag")
+	SetDumpCommentChar(';')
+
+	var buf bytes.Buffer
+	if err := DumpFlagsToWriter(&buf); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	expected := "dumpCommentCharFlag =   ; line1\n    ; line2\n"
+	if buf.String() != expected {
+		t.Fatalf("Unexpected dump %q. Expected %q", buf.String(), expected)
+	}
+
+	args, err := newConfigParser().parseReader("dump.ini", &buf)
+	if err != nil {
+		t.Fatalf("Cannot read dumped flags: %s", err)
+	}
+	for _, arg := range args {
+		if arg.Key == "dumpCommentCharFlag" && arg.Value != "" {
+			t.Fatalf("Unexpected value read back for dumpCommentCharFlag: [%s]", arg.Value)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expecting panic for unsupported comment char")
+		}
+	}()
+	SetDumpCommentChar('/')
+}
+
+func TestSetDumpFilter(t *testing.T) {
+	flag.String("dumpFilterVolatile", "2024-01-15", "flag for TestSetDumpFilter")
+	defer SetDumpFilter(nil)
+	SetDumpFilter(func(name string) bool {
+		return name != "dumpFilterVolatile"
+	})
+
+	var buf bytes.Buffer
+	if err := DumpFlagsToWriter(&buf); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	if strings.Contains(buf.String(), "dumpFilterVolatile") {
+		t.Fatalf("Dump mustn't contain filtered flag:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "\nx = ") {
+		t.Fatalf("Dump must contain x flag:\n%s", buf.String())
+	}
+}
+
+func TestDumpFlagsRoundTrip(t *testing.T) {
+	values := map[string]string{
+		"roundTripEmpty":       "",
+		"roundTripMultiline":   "line1\nline2\n",
+		"roundTripSpecial":     `a#b;c "d" \n\e`,
+		"roundTripQuoted":      `"quoted"`,
+		"roundTripSpaces":      "  padded  ",
+		"roundTripConditional": "prod?warn:debug",
+		"roundTripHeredoc":     "<<END",
+	}
+	for name, value := range values {
+		flag.String(name, "default", "flag for TestDumpFlagsRoundTrip\nwith multiline usage")
+		if err := flag.Lookup(name).Value.Set(value); err != nil {
+			t.Fatalf("Cannot set flag %s: %s", name, err)
+		}
+	}
+
+	var dump1 bytes.Buffer
+	if err := DumpFlagsToWriter(&dump1); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	for name := range values {
+		flag.Lookup(name).Value.Set("default")
+	}
+	args, err := newConfigParser().parseReader("dump.ini", bytes.NewReader(dump1.Bytes()))
+	if err != nil {
+		t.Fatalf("Cannot read dumped flags: %s", err)
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg.Key, "test.") {
+			// Flags registered by the testing package may not round-trip.
+			continue
+		}
+		if err := setFlagValue(flag.Lookup(arg.Key), arg.Value); err != nil {
+			t.Fatalf("Cannot set flag %s to %q: %s", arg.Key, arg.Value, err)
+		}
+	}
+	for name, value := range values {
+		if v := flag.Lookup(name).Value.String(); v != value {
+			t.Fatalf("Unexpected value for flag %s after round trip: %q. Expected %q", name, v, value)
+		}
+	}
+
+	var dump2 bytes.Buffer
+	if err := DumpFlagsToWriter(&dump2); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	if dump1.String() != dump2.String() {
+		t.Fatalf("Dumps differ after round trip:\n%s\n---\n%s", dump1.String(), dump2.String())
+	}
+}
+
+func TestOnReady(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	oldConfig := *config
+	oldX := *x
+	oldAllowMissingConfig := *allowMissingConfig
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		*allowMissingConfig = oldAllowMissingConfig
+		readyCallbacks = nil
+	}()
+	*config = path
+	*allowMissingConfig = false
+
+	readyCalls := 0
+	parsed = false
+	OnReady(func() {
+		readyCalls++
+	})
+
+	// missing config
+	if err := ParseWithContext(context.Background(), []string{"app"}); err == nil {
+		t.Fatalf("Expecting error for missing config")
+	}
+	if readyCalls != 0 {
+		t.Fatalf("OnReady callback mustn't be called if Parse fails")
+	}
+
+	if err := os.WriteFile(path, []byte("x = ready\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	parsed = false
+	if err := ParseWithContext(context.Background(), []string{"app"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if readyCalls != 1 {
+		t.Fatalf("Unexpected number of OnReady calls: %d. Expected 1", readyCalls)
+	}
+	TriggerReload()
+	if readyCalls != 1 {
+		t.Fatalf("OnReady callback mustn't be called on reload; got %d calls", readyCalls)
+	}
+}
+
+func TestParseWithContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "x = fromHTTP\n")
+	}))
+	defer ts.Close()
+	oldConfig := *config
+	oldX := *x
+	oldUnsecure := *unsecure
+	oldAllowMissingConfig := *allowMissingConfig
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		*unsecure = oldUnsecure
+		*allowMissingConfig = oldAllowMissingConfig
+	}()
+	*config = ts.URL + "/config.ini"
+	*unsecure = true
+	*allowMissingConfig = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	parsed = false
+	err := ParseWithContext(ctx, []string{"app"})
+	if !errors.Is(err, ErrConfigLoad) {
+		t.Fatalf("Unexpected error for canceled context: %v. Expected %v", err, ErrConfigLoad)
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.FilePath != *config {
+		t.Fatalf("Error %v must wrap *ParseError for %s", err, *config)
+	}
+	if !strings.Contains(err.Error(), "context canceled") {
+		t.Fatalf("Error %q must contain the actual cause", err)
+	}
+
+	parsed = false
+	if err := ParseWithContext(context.Background(), []string{"app"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *x != "fromHTTP" {
+		t.Fatalf("Unexpected x=[%s]. Expected [fromHTTP]", *x)
+	}
+	if err := ParseWithContext(context.Background(), []string{"app"}); err == nil {
+		t.Fatalf("Expecting error for duplicate call")
+	}
+}
+
+func TestParseE(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = \"unclosed\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	oldAllowMissingConfig := *allowMissingConfig
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		*allowMissingConfig = oldAllowMissingConfig
+		parsed = true
+	}()
+	*config = path
+	*allowMissingConfig = false
+
+	parsed = false
+	err := ParseE()
+	if !errors.Is(err, ErrConfigLoad) {
+		t.Fatalf("Unexpected error: %v. Expected %v", err, ErrConfigLoad)
+	}
+	if !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("Error must mention the file and the line: %s", err)
+	}
+	if parsed {
+		t.Fatalf("Flags mustn't be marked as parsed after failed ParseE()")
+	}
+
+	// retry after fixing the config
+	if err := os.WriteFile(path, []byte("x = \"closed\"\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	if err := ParseE(); err != nil {
+		t.Fatalf("Unexpected error on retry: %s", err)
+	}
+	if *x != "closed" {
+		t.Fatalf("Unexpected x=[%s]. Expected [closed]", *x)
+	}
+	if err := ParseE(); err == nil {
+		t.Fatalf("Expecting error for duplicate call")
+	}
+}
@@ -0,0 +1,36 @@
+// Package iniflagstest provides helpers for testing code, which uses iniflags.
+package iniflagstest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boomhut/iniflags"
+)
+
+// ReadIniFile writes the given config content to a temporary file,
+// reads it via iniflags and returns the map of keys to values.
+//
+// Keys don't need to correspond to registered flags. If a key occurs
+// multiple times, then the last value is returned. #import directives
+// are resolved relative to the temporary directory. t.Fatal is called
+// on error.
+func ReadIniFile(t testing.TB, content string) map[string]string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("iniflagstest: cannot write config file: %s", err)
+	}
+	args, err := iniflags.ReadIniFileWithOptions(path, iniflags.ReadOptions{
+		AllowUnknown: true,
+	})
+	if err != nil {
+		t.Fatalf("iniflagstest: cannot read config file: %s", err)
+	}
+	m := make(map[string]string, len(args))
+	for _, arg := range args {
+		m[arg.Key] = arg.Value
+	}
+	return m
+}
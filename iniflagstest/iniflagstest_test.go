@@ -0,0 +1,27 @@
+package iniflagstest
+
+import "testing"
+
+func TestReadIniFile(t *testing.T) {
+	m := ReadIniFile(t, `# comment
+addr = ":8080"
+[db]
+path = /var/db  # comment
+path = /var/db2
+list{,} = a
+list{,} = b
+`)
+	expected := map[string]string{
+		"addr": ":8080",
+		"path": "/var/db2",
+		"list": "a,b",
+	}
+	if len(m) != len(expected) {
+		t.Fatalf("Unexpected number of keys: %d. Expected %d: %v", len(m), len(expected), m)
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Fatalf("Unexpected value for key %q: %q. Expected %q", k, m[k], v)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build iniflags_keyring
+
+package iniflags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// The keyring:// value scheme is available when building with
+// -tags=iniflags_keyring. For example, "apiKey = keyring://myapp/alice"
+// reads the secret stored in the OS keyring for the service "myapp"
+// and the account "alice".
+//
+// The secret is read via github.com/zalando/go-keyring, which supports
+// Keychain on macOS, Secret Service on Linux and other unix systems,
+// and Credential Manager on Windows. Flags set via keyring:// values
+// are treated as marked via MarkSecret().
+func init() {
+	valueResolvers["keyring"] = resolveKeyring
+	secretValueSchemes["keyring"] = true
+}
+
+func resolveKeyring(ref string) (string, error) {
+	n := strings.IndexByte(ref, '/')
+	if n <= 0 || n == len(ref)-1 {
+		return "", fmt.Errorf("keyring reference must have the form keyring://service/account")
+	}
+	service, account := ref[:n], ref[n+1:]
+	secret, err := keyring.Get(service, account)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret for service %q, account %q from keyring: %s", service, account, err)
+	}
+	return secret, nil
+}
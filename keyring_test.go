@@ -0,0 +1,76 @@
+//go:build iniflags_keyring
+
+package iniflags
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestKeyring(t *testing.T) {
+	keyring.MockInit()
+	if err := keyring.Set("myapp", "alice", "s3cr3t"); err != nil {
+		t.Fatalf("Cannot store secret: %s", err)
+	}
+	apiKey := flag.String("keyringAPIKey", "", "flag for TestKeyring")
+	flag.Int("keyringPort", 0, "flag for TestKeyring")
+
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("keyringAPIKey = keyring://myapp/alice\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	defer func() {
+		*config = oldConfig
+		schemeSecretFlags.Delete("keyringAPIKey")
+		schemeSecretFlags.Delete("keyringPort")
+	}()
+	*config = path
+
+	if _, ok := parseConfigFlags(); !ok {
+		t.Fatalf("Cannot parse config with keyring reference")
+	}
+	if *apiKey != "s3cr3t" {
+		t.Fatalf("Unexpected keyringAPIKey=[%s]. Expected [s3cr3t]", *apiKey)
+	}
+
+	// flags resolved via keyring are treated as secret
+	var dump bytes.Buffer
+	if err := DumpFlagsToWriter(&dump); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	if strings.Contains(dump.String(), "s3cr3t") || strings.Contains(dump.String(), "keyringAPIKey") {
+		t.Fatalf("Secret from keyring mustn't be dumped:\n%s", dump.String())
+	}
+	if v := redactValue("keyringAPIKey", *apiKey); v != redactedValue {
+		t.Fatalf("Unexpected redacted value [%s]. Expected [%s]", v, redactedValue)
+	}
+
+	// invalid values from keyring are redacted in errors
+	if err := keyring.Set("myapp", "port", "80a"); err != nil {
+		t.Fatalf("Cannot store secret: %s", err)
+	}
+	_, err := resolveFlagValue(flag.Lookup("keyringPort"), "keyring://myapp/port", "")
+	if err == nil {
+		err = setFlagValue(flag.Lookup("keyringPort"), "80a")
+	}
+	if err == nil || !isSecretFlag("keyringPort") {
+		t.Fatalf("Expecting error for invalid port from keyring; err=%v", err)
+	}
+	if s := redactError("keyringPort", err, "80a").Error(); strings.Contains(s, "80a") {
+		t.Fatalf("Secret from keyring leaked to error: %s", s)
+	}
+
+	// missing secrets
+	if _, err := resolveKeyring("myapp/bob"); err == nil {
+		t.Fatalf("Expecting error for missing secret")
+	}
+	if _, err := resolveKeyring("myapp"); err == nil {
+		t.Fatalf("Expecting error for invalid reference")
+	}
+}
@@ -0,0 +1,174 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of flag value.
+//
+// It is inferred from the type of the value returned by flag.Getter
+// for flags created via the flag package, e.g. via flag.Int(), and for
+// flags created via this package, e.g. via StringSlice(). Flags with
+// custom flag.Value implementations have KindOther, since their string
+// representation may differ from the value returned by Get().
+type Kind int
+
+const (
+	// KindOther is the kind of flags with values of unknown types.
+	KindOther Kind = iota
+
+	// KindString is the kind of string flags.
+	KindString
+
+	// KindBool is the kind of bool flags.
+	KindBool
+
+	// KindInt is the kind of int and int64 flags.
+	KindInt
+
+	// KindUint is the kind of uint and uint64 flags.
+	KindUint
+
+	// KindFloat is the kind of float64 flags.
+	KindFloat
+
+	// KindDuration is the kind of time.Duration flags.
+	KindDuration
+
+	// KindList is the kind of list flags such as StringSlice.
+	KindList
+)
+
+// String returns human-readable name for the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindBool:
+		return "bool"
+	case KindInt:
+		return "int"
+	case KindUint:
+		return "uint"
+	case KindFloat:
+		return "float"
+	case KindDuration:
+		return "duration"
+	case KindList:
+		return "list"
+	default:
+		return "other"
+	}
+}
+
+var typeNormalizers = make(map[Kind]func(value string) (string, error))
+
+// SetTypeNormalizer registers fn for normalizing values of all the flags
+// with the given kind.
+//
+// fn is called with the value read from config file or environment variable
+// or set via SetOverrides() before it is set to the flag, so the normalized
+// value is applied. fn isn't called for values passed via command line,
+// since they are set by the flag package directly. For example, the following code clamps int flags to non-negative values:
+//
+//	iniflags.SetTypeNormalizer(iniflags.KindInt, func(v string) (string, error) {
+//		if strings.HasPrefix(v, "-") {
+//			return "0", nil
+//		}
+//		return v, nil
+//	})
+func SetTypeNormalizer(kind Kind, fn func(value string) (string, error)) {
+	if parsed {
+		logger.Panicf("iniflags: SetTypeNormalizer() must be called before Parse()")
+	}
+	typeNormalizers[kind] = fn
+}
+
+var normalizeDecimalComma bool
+
+// SetNormalizeDecimalComma enables accepting float values written
+// with a decimal comma such as "0,5".
+//
+// Such values are common in configs edited in locales using a decimal comma.
+// They are converted to "0.5" if normalization is enabled, while otherwise
+// they are rejected with an error suggesting the dot.
+func SetNormalizeDecimalComma(normalize bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetNormalizeDecimalComma() must be called before Parse()")
+	}
+	normalizeDecimalComma = normalize
+}
+
+// fixDecimalComma returns the float value with the decimal comma
+// replaced with a dot if normalization is enabled.
+func fixDecimalComma(value string) (string, error) {
+	if !isDecimalComma(value) {
+		return value, nil
+	}
+	fixed := strings.Replace(value, ",", ".", 1)
+	if !normalizeDecimalComma {
+		return "", fmt.Errorf("decimal comma isn't supported in float value [%s]; use a dot instead: [%s]", value, fixed)
+	}
+	return fixed, nil
+}
+
+// isDecimalComma returns true if s looks like a number with a decimal comma,
+// e.g. "0,5" or "-12,75".
+func isDecimalComma(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	n := strings.IndexByte(s, ',')
+	if n <= 0 || n == len(s)-1 {
+		return false
+	}
+	for _, c := range s[:n] + s[n+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// flagKind returns the kind of the flag f.
+func flagKind(f *flag.Flag) Kind {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return KindOther
+	}
+	if _, ok := getter.(*stringSliceValue); !ok && !isStdFlagValue(getter) {
+		// The string representation of custom values may differ
+		// from the value returned by Get().
+		return KindOther
+	}
+	switch getter.Get().(type) {
+	case string:
+		return KindString
+	case bool:
+		return KindBool
+	case int, int64:
+		return KindInt
+	case uint, uint64:
+		return KindUint
+	case float64:
+		return KindFloat
+	case time.Duration:
+		return KindDuration
+	case []string:
+		return KindList
+	default:
+		return KindOther
+	}
+}
+
+// isStdFlagValue returns true if v is created via the flag package,
+// e.g. via flag.Int().
+func isStdFlagValue(v flag.Value) bool {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.PkgPath() == "flag"
+}
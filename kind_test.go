@@ -0,0 +1,121 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFlagKind(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("s", "", "")
+	fs.Bool("b", false, "")
+	fs.Int64("i", 0, "")
+	fs.Uint("u", 0, "")
+	fs.Float64("f", 0, "")
+	fs.Duration("d", 0, "")
+	fs.Var(&stringSliceValue{p: new([]string)}, "l", "")
+	fs.Var(&customIntValue{}, "c", "")
+	expected := map[string]Kind{
+		"s": KindString,
+		"b": KindBool,
+		"i": KindInt,
+		"u": KindUint,
+		"f": KindFloat,
+		"d": KindDuration,
+		"l": KindList,
+		"c": KindOther,
+	}
+	for name, kind := range expected {
+		if k := flagKind(fs.Lookup(name)); k != kind {
+			t.Fatalf("Unexpected kind for flag %s: %s. Expected %s", name, k, kind)
+		}
+	}
+}
+
+// customIntValue is a custom flag value with string representation
+// such as "1k" differing from the value returned by Get().
+type customIntValue struct {
+	n int
+}
+
+func (v *customIntValue) String() string {
+	if v.n%1000 == 0 && v.n != 0 {
+		return fmt.Sprintf("%dk", v.n/1000)
+	}
+	return fmt.Sprintf("%d", v.n)
+}
+
+func (v *customIntValue) Set(s string) error {
+	mult := 1
+	if strings.HasSuffix(s, "k") {
+		s = s[:len(s)-1]
+		mult = 1000
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	v.n = n * mult
+	return nil
+}
+
+func (v *customIntValue) Get() interface{} {
+	return v.n
+}
+
+func TestSetTypeNormalizer(t *testing.T) {
+	defer delete(typeNormalizers, KindString)
+
+	parsed = false
+	SetTypeNormalizer(KindString, func(v string) (string, error) {
+		return strings.ToLower(v), nil
+	})
+	value, err := resolveFlagValue(flag.Lookup("x"), "FooBar", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if value != "foobar" {
+		t.Fatalf("Unexpected value [%s]. Expected [foobar]", value)
+	}
+}
+
+func TestSetNormalizeDecimalComma(t *testing.T) {
+	defer func() { normalizeDecimalComma = false }()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Float64("ratio", 0, "")
+	f := fs.Lookup("ratio")
+
+	_, err := resolveFlagValue(f, "0,5", "")
+	if err == nil {
+		t.Fatalf("Expecting error for decimal comma")
+	}
+	if !strings.Contains(err.Error(), "[0.5]") {
+		t.Fatalf("Error must suggest the value with a dot: %s", err)
+	}
+
+	parsed = false
+	SetNormalizeDecimalComma(true)
+	for raw, expected := range map[string]string{
+		"0,5":       "0.5",
+		"-12,75":    "-12.75",
+		"1.5":       "1.5",
+		"1,000,000": "1,000,000",
+	} {
+		value, err := resolveFlagValue(f, raw, "")
+		if err != nil {
+			t.Fatalf("Unexpected error for [%s]: %s", raw, err)
+		}
+		if value != expected {
+			t.Fatalf("Unexpected value for [%s]: [%s]. Expected [%s]", raw, value, expected)
+		}
+	}
+
+	// Non-float flags aren't affected.
+	value, err := resolveFlagValue(flag.Lookup("x"), "0,5", "")
+	if err != nil || value != "0,5" {
+		t.Fatalf("Unexpected value for string flag: [%s], err: %v", value, err)
+	}
+}
@@ -0,0 +1,72 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+var (
+	overrides     map[string]string
+	overrideFlags = make(map[string]bool) // Flags set via SetOverrides()
+)
+
+// SetOverrides sets flag values computed by the application, which
+// override values from config files.
+//
+// This is useful for runtime-derived settings such as the number of workers
+// depending on the number of CPUs. Flag value precedence is:
+//
+//   - value set via command line
+//   - value from SetOverrides()
+//   - value from config file
+//   - value from environment variable if enabled via SetFromProcessEnv()
+//   - default value
+//
+// Flags set via overrides aren't changed on config reload.
+func SetOverrides(values map[string]string) {
+	if parsed {
+		logger.Panicf("iniflags: SetOverrides() must be called before Parse()")
+	}
+	overrides = make(map[string]string, len(values))
+	for k, v := range values {
+		overrides[k] = v
+	}
+}
+
+// applyOverrides applies values set via SetOverrides() to flags not set
+// via command line.
+func applyOverrides() error {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	missingFlags := getMissingFlags()
+	for _, name := range names {
+		fullName := name
+		if s, ok := flagShorthands[name]; ok {
+			fullName = s
+		}
+		f := flag.Lookup(fullName)
+		if f == nil {
+			return fmt.Errorf("iniflags: unknown flag [%s] passed to SetOverrides()", name)
+		}
+		if !missingFlags[f.Name] {
+			continue
+		}
+		value, err := resolveFlagValue(f, overrides[name], "")
+		if err == nil {
+			err = setFlagValue(f, value)
+		}
+		if err != nil {
+			return fmt.Errorf("iniflags: error when setting flag [%s] to override value [%s]: [%s]", f.Name, redactValue(f.Name, overrides[name]), redactError(f.Name, err, overrides[name], value))
+		}
+		overrideFlags[f.Name] = true
+		if traceHook != nil {
+			traceHook("override", f.Name, redactValue(f.Name, f.Value.String()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package iniflags
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestSetOverrides(t *testing.T) {
+	workers := flag.Int("overrideWorkers", 1, "flag for TestSetOverrides")
+	defer func() {
+		overrides = nil
+		overrideFlags = make(map[string]bool)
+	}()
+
+	parsed = false
+	SetOverrides(map[string]string{"overrideWorkers": "8"})
+	if err := applyOverrides(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *workers != 8 {
+		t.Fatalf("Unexpected overrideWorkers=%d. Expected 8", *workers)
+	}
+	if getMissingFlags()["overrideWorkers"] {
+		t.Fatalf("Flags set via overrides mustn't be overridden by config")
+	}
+
+	SetOverrides(map[string]string{"unknownOverride": "foo"})
+	if err := applyOverrides(); err == nil {
+		t.Fatalf("Expecting error for unknown flag")
+	}
+}
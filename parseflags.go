@@ -0,0 +1,111 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// ParseError describes an error found by ParseFlags().
+type ParseError struct {
+	// FilePath is the path to the config containing the error.
+	FilePath string
+
+	// LineNum is the line number with the error. It is 0 if the config
+	// cannot be parsed at all.
+	LineNum int
+
+	// Key is the flag name the error relates to. It is empty if the config
+	// cannot be parsed at all.
+	Key string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error interface.
+func (e *ParseError) Error() string {
+	if e.Key == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("iniflags: cannot set flag [%s] at line [%d] of file [%s]: %s", e.Key, e.LineNum, e.FilePath, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseFlags parses ini config contents and applies the values to flagSet.
+//
+// Unlike Parse(), it doesn't depend on iniflags settings such as
+// SetPreprocessor(), RegisterShorthand() or MarkSecret() and doesn't modify
+// iniflags state, so it may be used by libraries owning their flag sets.
+// Parse() applies config files via the same pipeline.
+//
+// configPath is the path to the config the contents are read from.
+// #import directives are resolved relative to its directory, and it is
+// used as FilePath in the returned errors. Imported configs may be fetched
+// only via https. Keys found in shorthands are mapped to the corresponding
+// flag names. Values are applied verbatim, e.g. file references and value
+// schemes aren't resolved. Values aren't redacted in the returned errors.
+//
+// All the errors found are returned, so a single invalid value
+// doesn't prevent setting the remaining flags. Nil is returned on success.
+func ParseFlags(content string, flagSet *flag.FlagSet, configPath string, shorthands map[string]string) []ParseError {
+	p := &configParser{}
+	args, err := p.parseReader(configPath, strings.NewReader(content))
+	if err != nil {
+		return []ParseError{{FilePath: configPath, Err: err}}
+	}
+	return applyArgs(flagSet, args, shorthands, func(f *flag.Flag, arg FlagArg) error {
+		if f == nil {
+			return fmt.Errorf("unknown flag")
+		}
+		oldValue := f.Value.String()
+		if err := setFlagValue(f, strings.TrimSpace(arg.Value)); err != nil {
+			restoreFlagValue(f, oldValue)
+			return err
+		}
+		return nil
+	})
+}
+
+// applyArgs applies args read from configs to the flags registered
+// in flagSet and returns the errors found.
+//
+// Keys without the corresponding flags are mapped to flag names
+// via shorthands. set is called for every arg with arg.Key containing
+// the flag name. f is nil if flagSet has no flag with this name.
+// Type annotations are rejected for registered flags.
+func applyArgs(flagSet *flag.FlagSet, args []FlagArg, shorthands map[string]string, set func(f *flag.Flag, arg FlagArg) error) []ParseError {
+	var errs []ParseError
+	for _, arg := range args {
+		f := flagSet.Lookup(arg.Key)
+		if f == nil {
+			if fullName, ok := shorthands[arg.Key]; ok {
+				f = flagSet.Lookup(fullName)
+				arg.Key = fullName
+			}
+		}
+		var err error
+		if f != nil && arg.Type != "" {
+			err = fmt.Errorf("type annotation [%s] isn't allowed for the registered flag", arg.Type)
+		} else {
+			err = set(f, arg)
+		}
+		if err != nil {
+			errs = append(errs, newParseError(arg, err))
+		}
+	}
+	return errs
+}
+
+func newParseError(arg FlagArg, err error) ParseError {
+	return ParseError{
+		FilePath: arg.FilePath,
+		LineNum:  arg.LineNum,
+		Key:      arg.Key,
+		Err:      err,
+	}
+}
@@ -0,0 +1,102 @@
+package iniflags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/base.ini", []byte("name = base\nport = 80\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	name := fs.String("name", "default", "")
+	port := fs.Int("port", 0, "")
+	debug := fs.Bool("debug", false, "")
+
+	content := `
+#import "base.ini"
+port = 8080
+d = true
+`
+	generation := Generation
+	if errs := ParseFlags(content, fs, dir+"/app.ini", map[string]string{"d": "debug"}); errs != nil {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if *name != "base" || *port != 8080 || !*debug {
+		t.Fatalf("Unexpected values: name=%q, port=%d, debug=%v", *name, *port, *debug)
+	}
+	if Generation != generation {
+		t.Fatalf("ParseFlags mustn't change Generation")
+	}
+}
+
+func TestParseFlagsErrors(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	port := fs.Int("port", 123, "")
+	name := fs.String("name", "", "")
+
+	content := `port = foo
+unknown = 1
+name = bar
+`
+	errs := ParseFlags(content, fs, "app.ini", nil)
+	if len(errs) != 2 {
+		t.Fatalf("Unexpected errors: %v. Expected 2 errors", errs)
+	}
+	if errs[0].Key != "port" || errs[0].LineNum != 1 || errs[0].FilePath != "app.ini" {
+		t.Fatalf("Unexpected error: %+v", errs[0])
+	}
+	if errs[0].Err == nil || errors.Unwrap(&errs[0]) != errs[0].Err {
+		t.Fatalf("Unexpected underlying error: %v", errs[0].Err)
+	}
+	if errs[1].Key != "unknown" || errs[1].LineNum != 2 {
+		t.Fatalf("Unexpected error: %+v", errs[1])
+	}
+	if *port != 123 || *name != "bar" {
+		t.Fatalf("Unexpected values: port=%d, name=%q", *port, *name)
+	}
+
+	// syntax error
+	errs = ParseFlags("port\n", fs, "app.ini", nil)
+	if len(errs) != 1 || errs[0].Key != "" || errs[0].LineNum != 0 {
+		t.Fatalf("Unexpected errors: %+v", errs)
+	}
+}
+
+func TestParseFlagsIgnoresGlobalSettings(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, "name = remote\n")
+	}))
+	defer ts.Close()
+
+	*unsecure = true
+	flagShorthands["parseFlagsShorthand"] = "name"
+	defer func() {
+		*unsecure = false
+		delete(flagShorthands, "parseFlagsShorthand")
+	}()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	name := fs.String("name", "default", "")
+
+	// -unsecure doesn't allow imports via http
+	errs := ParseFlags(fmt.Sprintf("#import %q\n", ts.URL+"/base.ini"), fs, "app.ini", nil)
+	if len(errs) != 1 || requests != 0 || *name != "default" {
+		t.Fatalf("Unexpected errors: %v; requests: %d; name=%q", errs, requests, *name)
+	}
+
+	// shorthands registered via RegisterShorthand() aren't used
+	errs = ParseFlags("parseFlagsShorthand = foo\n", fs, "app.ini", nil)
+	if len(errs) != 1 || errs[0].Key != "parseFlagsShorthand" || *name != "default" {
+		t.Fatalf("Unexpected errors: %v; name=%q", errs, *name)
+	}
+}
@@ -0,0 +1,140 @@
+//go:build iniflags_proto
+
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DumpFlagsAsProto writes values for all the flags defined in the application
+// to w in protobuf text format according to the given message descriptor.
+//
+// It is available when building with -tags=iniflags_proto.
+//
+// Flags are matched to fields case-insensitively with '-' and '_' chars
+// ignored, so -log-level and -logLevel flags match log_level field.
+// Dots in flag names select nested message fields, e.g. -db.max-conns
+// matches max_conns field of db message field. List flags are written
+// as repeated fields. Flags without the corresponding field, flags
+// excluded from dump and flags holding sensitive values are skipped.
+func DumpFlagsAsProto(w io.Writer, descriptor protoreflect.MessageDescriptor) error {
+	values := make(map[string]string)
+	kinds := make(map[string]Kind)
+	flag.VisitAll(func(f *flag.Flag) {
+		if flagsToExcludeFromDump[f.Name] || isSecretFlag(f.Name) {
+			return
+		}
+		if dumpFilter != nil && !dumpFilter(f.Name) {
+			return
+		}
+		path := protoFieldPath(f.Name)
+		values[path] = dumpValue(f.Value)
+		kinds[path] = flagKind(f)
+	})
+	var sb strings.Builder
+	if err := writeProtoFields(&sb, descriptor, "", "", values, kinds); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeProtoFields(sb *strings.Builder, md protoreflect.MessageDescriptor, prefix, indent string, values map[string]string, kinds map[string]Kind) error {
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + normalizeProtoName(string(fd.Name()))
+		name := string(fd.Name())
+		if fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+			if !hasProtoPathPrefix(values, path+".") {
+				continue
+			}
+			fmt.Fprintf(sb, "%s%s {\n", indent, name)
+			if err := writeProtoFields(sb, fd.Message(), path+".", indent+"  ", values, kinds); err != nil {
+				return err
+			}
+			fmt.Fprintf(sb, "%s}\n", indent)
+			continue
+		}
+		value, ok := values[path]
+		if !ok {
+			continue
+		}
+		items := []string{value}
+		if fd.IsList() {
+			if kinds[path] == KindList {
+				var err error
+				if items, err = parseList(value); err != nil {
+					return fmt.Errorf("iniflags: cannot parse list value [%s] for field [%s]: %s", value, fd.FullName(), err)
+				}
+			}
+		}
+		for _, item := range items {
+			v, err := formatProtoValue(fd, item)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(sb, "%s%s: %s\n", indent, name, v)
+		}
+	}
+	return nil
+}
+
+func formatProtoValue(fd protoreflect.FieldDescriptor, value string) (string, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return strconv.Quote(value), nil
+	case protoreflect.BoolKind:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "", fmt.Errorf("iniflags: cannot write value [%s] for bool field [%s]: %s", value, fd.FullName(), err)
+		}
+		return value, nil
+	case protoreflect.EnumKind:
+		return value, nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", fmt.Errorf("iniflags: cannot write value [%s] for float field [%s]: %s", value, fd.FullName(), err)
+		}
+		return value, nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "", fmt.Errorf("iniflags: cannot write value [%s] for message field [%s]", value, fd.FullName())
+	default:
+		// integer kinds
+		if _, err := strconv.ParseInt(value, 0, 64); err != nil {
+			if _, err := strconv.ParseUint(value, 0, 64); err != nil {
+				return "", fmt.Errorf("iniflags: cannot write value [%s] for integer field [%s]: %s", value, fd.FullName(), err)
+			}
+		}
+		return value, nil
+	}
+}
+
+// protoFieldPath returns the normalized path to proto field
+// for the given flag name.
+func protoFieldPath(flagName string) string {
+	parts := strings.Split(flagName, ".")
+	for i, part := range parts {
+		parts[i] = normalizeProtoName(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+func normalizeProtoName(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("-", "", "_", "").Replace(name)
+}
+
+func hasProtoPathPrefix(values map[string]string, prefix string) bool {
+	for path := range values {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
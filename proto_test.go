@@ -0,0 +1,68 @@
+//go:build iniflags_proto
+
+package iniflags
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var (
+	protoMaxConns = flag.Int("protoDb.max-conns", 10, "flag for TestDumpFlagsAsProto")
+	protoPaths    = StringSlice("proto_paths", []string{"/a", "/b"}, "flag for TestDumpFlagsAsProto")
+)
+
+func TestDumpFlagsAsProto(t *testing.T) {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("config.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Config"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("x"), Number: proto.Int32(1), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{Name: proto.String("proto_db"), Number: proto.Int32(2), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".test.Config.Db")},
+				{Name: proto.String("proto_paths"), Number: proto.Int32(3), Label: repeated, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{Name: proto.String("missing"), Number: proto.Int32(4), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum()},
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Db"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("max_conns"), Number: proto.Int32(1), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()},
+				},
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatalf("Cannot create file descriptor: %s", err)
+	}
+	oldX := *x
+	defer func() { *x = oldX }()
+	*x = "foo"
+
+	var buf bytes.Buffer
+	if err := DumpFlagsAsProto(&buf, fd.Messages().ByName("Config")); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	expected := `x: "foo"
+proto_db {
+  max_conns: 10
+}
+proto_paths: "/a"
+proto_paths: "/b"
+`
+	if buf.String() != expected {
+		t.Fatalf("Unexpected dump:\n%s\nExpected:\n%s", buf.String(), expected)
+	}
+	if *protoMaxConns != 10 || len(*protoPaths) != 2 {
+		t.Fatalf("Flags mustn't be modified by dump")
+	}
+}
@@ -0,0 +1,79 @@
+package iniflags
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"strconv"
+)
+
+type jsonSchema struct {
+	Schema     string                        `json:"$schema"`
+	Type       string                        `json:"type"`
+	Properties map[string]jsonSchemaProperty `json:"properties"`
+}
+
+type jsonSchemaProperty struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// WriteJSONSchema writes JSON Schema describing all the flags defined
+// in the application to w.
+//
+// Every flag is described by a property with the type inferred from
+// the flag's value, the description taken from the flag's usage and
+// the flag's default value. Flags excluded via ExcludeFlagFromDump()
+// are omitted from the schema, while defaults are omitted for flags
+// marked via MarkSecret().
+func WriteJSONSchema(w io.Writer) error {
+	schema := jsonSchema{
+		Schema:     "http://json-schema.org/draft-07/schema#",
+		Type:       "object",
+		Properties: make(map[string]jsonSchemaProperty),
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, exclude := flagsToExcludeFromDump[f.Name]; exclude {
+			return
+		}
+		p := jsonSchemaPropertyForFlag(f)
+		if sensitiveFlags[f.Name] {
+			p.Default = nil
+		}
+		schema.Properties[f.Name] = p
+	})
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(schema)
+}
+
+func jsonSchemaPropertyForFlag(f *flag.Flag) jsonSchemaProperty {
+	p := jsonSchemaProperty{
+		Type:        "string",
+		Description: f.Usage,
+		Default:     f.DefValue,
+	}
+	switch flagKind(f) {
+	case KindBool:
+		p.Type = "boolean"
+		if v, err := strconv.ParseBool(f.DefValue); err == nil {
+			p.Default = v
+		}
+	case KindInt, KindUint:
+		p.Type = "integer"
+		if v, err := strconv.ParseInt(f.DefValue, 0, 64); err == nil {
+			p.Default = v
+		} else if v, err := strconv.ParseUint(f.DefValue, 0, 64); err == nil {
+			p.Default = v
+		}
+	case KindFloat:
+		p.Type = "number"
+		if v, err := strconv.ParseFloat(f.DefValue, 64); err == nil {
+			p.Default = v
+		}
+	case KindDuration:
+		// Durations are written in config files as strings like "1m30s".
+	}
+	return p
+}
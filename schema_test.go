@@ -0,0 +1,48 @@
+package iniflags
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"testing"
+	"time"
+)
+
+var (
+	schemaTestBool     = flag.Bool("schemaTestBool", true, "bool flag for TestWriteJSONSchema")
+	schemaTestInt      = flag.Int("schemaTestInt", 42, "int flag for TestWriteJSONSchema")
+	schemaTestDuration = flag.Duration("schemaTestDuration", time.Second, "duration flag for TestWriteJSONSchema")
+)
+
+func TestWriteJSONSchema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSONSchema(&buf); err != nil {
+		t.Fatalf("Cannot write JSON schema: %s", err)
+	}
+	var schema struct {
+		Properties map[string]struct {
+			Type        string
+			Description string
+			Default     interface{}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &schema); err != nil {
+		t.Fatalf("Cannot parse JSON schema: %s\n%s", err, buf.String())
+	}
+
+	p := schema.Properties["schemaTestBool"]
+	if p.Type != "boolean" || p.Default != true || p.Description != "bool flag for TestWriteJSONSchema" {
+		t.Fatalf("Unexpected property for bool flag: %+v", p)
+	}
+	p = schema.Properties["schemaTestInt"]
+	if p.Type != "integer" || p.Default != float64(42) {
+		t.Fatalf("Unexpected property for int flag: %+v", p)
+	}
+	p = schema.Properties["schemaTestDuration"]
+	if p.Type != "string" || p.Default != "1s" {
+		t.Fatalf("Unexpected property for duration flag: %+v", p)
+	}
+	if _, found := schema.Properties["config"]; found {
+		t.Fatalf("Flags excluded from dump must be excluded from JSON schema")
+	}
+}
@@ -0,0 +1,91 @@
+package iniflags
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// redactedValue replaces values of secret flags in logs and errors.
+const redactedValue = "<redacted>"
+
+// MarkSecret marks the flag with the given name as holding a sensitive value
+// such as a password or an API key.
+//
+// The flag is excluded from -dumpflags output and other dumps, and its value
+// is redacted in iniflags' log messages, errors and values passed
+// to the hook set via SetTraceHook(). The name may be a shorthand
+// registered via RegisterShorthand().
+//
+// Must be called before Parse().
+func MarkSecret(name string) {
+	if parsed {
+		logger.Panicf("iniflags: MarkSecret() must be called before Parse()")
+	}
+	if fullName, ok := flagShorthands[name]; ok {
+		name = fullName
+	}
+	sensitiveFlags[name] = true
+}
+
+// secretValueSchemes contains value schemes, which resolve to sensitive
+// values, e.g. keyring.
+var secretValueSchemes = make(map[string]bool)
+
+// schemeSecretFlags contains names of flags set via values with schemes
+// from secretValueSchemes. Such flags are treated as marked via MarkSecret().
+var schemeSecretFlags sync.Map
+
+// isSecretFlag returns true if the flag with the given name or shorthand
+// is marked via MarkSecret() or is set via a value with a secret scheme.
+func isSecretFlag(name string) bool {
+	if fullName, ok := flagShorthands[name]; ok {
+		name = fullName
+	}
+	if sensitiveFlags[name] {
+		return true
+	}
+	_, ok := schemeSecretFlags.Load(name)
+	return ok
+}
+
+// isSecretKey returns true if the config key refers to the flag marked
+// via MarkSecret().
+//
+// The key may contain the delimiter of multiline values such as "token{,}"
+// and the type annotation such as "token:string".
+func isSecretKey(key string) bool {
+	if strings.HasSuffix(key, "}") {
+		if n := strings.LastIndexByte(key, '{'); n >= 0 {
+			key = key[:n]
+		}
+	}
+	key, _ = splitKeyType(strings.TrimSpace(key))
+	return isSecretFlag(key)
+}
+
+// redactValue returns the value suitable for logging for the flag
+// with the given name.
+func redactValue(name, value string) string {
+	if isSecretFlag(name) {
+		return redactedValue
+	}
+	return value
+}
+
+// redactError removes the given values of the secret flag with the given name
+// from err.
+//
+// Errors returned by flag.Value.Set often contain the value being set.
+func redactError(name string, err error, values ...string) error {
+	if !isSecretFlag(name) {
+		return err
+	}
+	s := err.Error()
+	for _, value := range values {
+		if value != "" {
+			s = strings.Replace(s, value, redactedValue, -1)
+		}
+	}
+	return errors.New(s)
+}
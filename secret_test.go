@@ -0,0 +1,118 @@
+package iniflags
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMarkSecret(t *testing.T) {
+	password := flag.String("secretPassword", "", "flag for TestMarkSecret")
+	flag.Int("secretPin", 0, "flag for TestMarkSecret")
+
+	var logBuf bytes.Buffer
+	oldLogger := logger
+	oldConfig := *config
+	var traced []string
+	defer func() {
+		SetLogger(oldLogger)
+		SetLogLevel(LogInfo)
+		*config = oldConfig
+		traceHook = nil
+		rejectControlChars = false
+		delete(sensitiveFlags, "secretPassword")
+		delete(sensitiveFlags, "secretPin")
+		delete(pendingCallbacks, "secretPassword")
+	}()
+	SetLogger(log.New(&logBuf, "", 0))
+	SetLogLevel(LogDebug)
+	traceHook = func(source, key, value string) {
+		traced = append(traced, key+"="+value)
+	}
+
+	parsed = false
+	MarkSecret("secretPassword")
+	MarkSecret("secretPin")
+	if names := strings.Join(SensitiveFlagNames(), ","); names != "secretPassword,secretPin" {
+		t.Fatalf("Unexpected sensitive flag names: %s", names)
+	}
+
+	// debug messages
+	if _, err := newConfigParser().parseReader("secret.ini", strings.NewReader(`secretPassword = "hunter1"`+"\n")); err != nil {
+		t.Fatalf("Cannot read config: %s", err)
+	}
+
+	// config syntax errors
+	f := func(content string) {
+		t.Helper()
+		_, err := newConfigParser().parseReader("secret.ini", strings.NewReader(content))
+		if err == nil {
+			t.Fatalf("Expecting error for %q", content)
+		}
+		if strings.Contains(err.Error(), "hunter9") {
+			t.Fatalf("Secret value leaked to error: %s", err)
+		}
+	}
+	f(`secretPassword = "hunter9` + "\n")
+	f(`secretPassword{,} = "hunter9` + "\n")
+	f("secretPassword hunter9\n")
+	rejectControlChars = true
+	f("secretPassword = hunter9\x01\n")
+	rejectControlChars = false
+	if _, _, _, err := DecodeConfigLine(`secretPassword = "hunter9`); err == nil || strings.Contains(err.Error(), "hunter9") {
+		t.Fatalf("Expecting redacted error from DecodeConfigLine; got %v", err)
+	}
+	if _, _, _, err := DecodeConfigLine("secretPassword hunter9"); err == nil || strings.Contains(err.Error(), "hunter9") {
+		t.Fatalf("Expecting redacted error from DecodeConfigLine; got %v", err)
+	}
+
+	// invalid value errors
+	if _, _, _, ok := applyFlagArgs([]FlagArg{{Key: "secretPin", Value: "12ab34"}}); ok {
+		t.Fatalf("Expecting error for invalid secretPin value")
+	}
+
+	// reload messages and trace hook
+	path := t.TempDir() + "/secret.ini"
+	if err := os.WriteFile(path, []byte("secretPassword = hunter2\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	*config = path
+	parsed = true
+	TriggerReload()
+	if *password != "hunter2" {
+		t.Fatalf("Unexpected secretPassword=[%s]. Expected [hunter2]", *password)
+	}
+
+	if err := SetFlagValueWithoutCallback("secretPassword", "hunter3"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := SetFlagValueWithoutCallback("secretPin", "56cd78"); err == nil || strings.Contains(err.Error(), "56cd78") {
+		t.Fatalf("Expecting redacted error for invalid secretPin value; got %v", err)
+	}
+
+	logs := logBuf.String()
+	for _, secret := range []string{"hunter1", "hunter2", "12ab34"} {
+		if strings.Contains(logs, secret) {
+			t.Fatalf("Secret value %q leaked to logs:\n%s", secret, logs)
+		}
+	}
+	if !strings.Contains(logs, "secretPassword:<redacted>") {
+		t.Fatalf("Reload message must mention the redacted flag:\n%s", logs)
+	}
+	for _, s := range traced {
+		if strings.HasPrefix(s, "secret") && !strings.HasSuffix(s, "="+redactedValue) {
+			t.Fatalf("Secret value leaked to trace hook: %q", s)
+		}
+	}
+
+	var dump bytes.Buffer
+	if err := DumpFlagsToWriter(&dump); err != nil {
+		t.Fatalf("Cannot dump flags: %s", err)
+	}
+	if strings.Contains(dump.String(), "secretPassword") {
+		t.Fatalf("Secret flag mustn't be dumped:\n%s", dump.String())
+	}
+}
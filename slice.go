@@ -0,0 +1,194 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// sliceValue is implemented by flag values holding a list of items.
+//
+// Values read from config files are applied to such flags via Replace,
+// so the list may be written either as "a, b, c" or as "[a, b, c]".
+type sliceValue interface {
+	flag.Value
+	Replace(items []string) error
+}
+
+var (
+	sliceMergeCLIAndConfig bool
+	cliSliceItems          = make(map[string][]string) // Items of list flags set via command line
+)
+
+// SetSliceMergeCLIAndConfig enables merging values for list flags such as
+// StringSlice set both via command line and in config files.
+//
+// By default list flags set via command line ignore config files as any
+// other flags. If merging is enabled, items from the config file go first,
+// followed by items set via command line. For example, "path = [/a, /b]"
+// in the config and -path=/c on the command line result in [/a, /b, /c].
+// This allows augmenting a base list from the config with extra items.
+//
+// Must be called before Parse().
+func SetSliceMergeCLIAndConfig(merge bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetSliceMergeCLIAndConfig() must be called before Parse()")
+	}
+	sliceMergeCLIAndConfig = merge
+}
+
+// saveCLISliceItems saves items of list flags set via command line,
+// so they can be merged with items from config files on every config load.
+func saveCLISliceItems() error {
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		if _, ok := f.Value.(sliceValue); !ok || err != nil {
+			return
+		}
+		var items []string
+		if items, err = parseList(f.Value.String()); err != nil {
+			err = fmt.Errorf("iniflags: cannot parse list flag [%s] set via command line: %s", f.Name, err)
+			return
+		}
+		cliSliceItems[f.Name] = items
+	})
+	return err
+}
+
+// mergeSliceItems returns the list value with cliItems appended
+// to the items from the config value.
+func mergeSliceItems(value string, cliItems []string) (string, error) {
+	items, err := parseList(value)
+	if err != nil {
+		return "", err
+	}
+	return formatList(append(items, cliItems...)), nil
+}
+
+type stringSliceValue struct {
+	p       *[]string
+	changed bool
+}
+
+// StringSlice defines a flag with the given name, default value and usage,
+// which holds a list of strings.
+//
+// The flag may be repeated on the command line, e.g. -path=a -path=b,
+// while in config files the list is written on a single line
+// as either "path = a, b" or "path = [a, b]". Items containing commas
+// may be double-quoted: path = ["a,b", c].
+//
+// The return value is the address of a slice variable that stores
+// the value of the flag.
+func StringSlice(name string, value []string, usage string) *[]string {
+	p := new([]string)
+	StringSliceVar(p, name, value, usage)
+	return p
+}
+
+// StringSliceVar defines a flag with the given name, default value
+// and usage, which stores the list of strings into p.
+//
+// See StringSlice() for details.
+func StringSliceVar(p *[]string, name string, value []string, usage string) {
+	*p = append([]string(nil), value...)
+	flag.Var(&stringSliceValue{p: p}, name, usage)
+}
+
+func (v *stringSliceValue) Set(s string) error {
+	items, err := parseList(s)
+	if err != nil {
+		return err
+	}
+	if !v.changed {
+		// the first Set call overrides the default value
+		*v.p = items
+		v.changed = true
+		return nil
+	}
+	*v.p = append(*v.p, items...)
+	return nil
+}
+
+func (v *stringSliceValue) Replace(items []string) error {
+	*v.p = append([]string(nil), items...)
+	return nil
+}
+
+func (v *stringSliceValue) Get() interface{} {
+	if v.p == nil {
+		return []string(nil)
+	}
+	return *v.p
+}
+
+func (v *stringSliceValue) String() string {
+	if v.p == nil {
+		// zero value created by flag.isZeroValue
+		return "[]"
+	}
+	return formatList(*v.p)
+}
+
+// parseList parses a list written either as "a, b, c" or as "[a, b, c]".
+//
+// Items may be double-quoted in order to include commas.
+func parseList(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	if s == "" {
+		return []string{}, nil
+	}
+
+	var items []string
+	var item []byte
+	keep := 0 // length of the item prefix, which mustn't be trimmed
+	flush := func() {
+		for len(item) > keep && (item[len(item)-1] == ' ' || item[len(item)-1] == '\t') {
+			item = item[:len(item)-1]
+		}
+		items = append(items, string(item))
+		item = item[:0]
+		keep = 0
+	}
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(s):
+			i++
+			item = append(item, s[i])
+		case c == '"':
+			quoted = !quoted
+			keep = len(item)
+		case c == ',' && !quoted:
+			flush()
+		case !quoted && len(item) == 0 && (c == ' ' || c == '\t'):
+			// skip leading whitespace
+		default:
+			item = append(item, c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unclosed quote in the list [%s]", s)
+	}
+	flush()
+	return items, nil
+}
+
+// formatList formats items as "[a, b, c]", so they may be parsed
+// back with parseList.
+func formatList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		if item == "" || strings.ContainsAny(item, ",\"\\[]") || strings.TrimSpace(item) != item {
+			item = strings.Replace(item, "\\", "\\\\", -1)
+			item = strings.Replace(item, "\"", "\\\"", -1)
+			item = "\"" + item + "\""
+		}
+		quoted[i] = item
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
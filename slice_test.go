@@ -0,0 +1,90 @@
+package iniflags
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	tests := map[string][]string{
+		"a, b, c":           {"a", "b", "c"},
+		"[a, b, c]":         {"a", "b", "c"},
+		"[]":                {},
+		`["a,b", c]`:        {"a,b", "c"},
+		`[" x ", "q\"t"]`:   {" x ", `q"t`},
+		"[/var/log, /tmp ]": {"/var/log", "/tmp"},
+	}
+	for s, expected := range tests {
+		items, err := parseList(s)
+		if err != nil {
+			t.Fatalf("Cannot parse list %q: %s", s, err)
+		}
+		if strings.Join(items, "|") != strings.Join(expected, "|") || len(items) != len(expected) {
+			t.Fatalf("Unexpected items %q parsed from %q. Expected %q", items, s, expected)
+		}
+		formatted := formatList(items)
+		if items2, err := parseList(formatted); err != nil || strings.Join(items2, "|") != strings.Join(items, "|") {
+			t.Fatalf("Cannot parse formatted list %q back: %q, err=%v", formatted, items2, err)
+		}
+	}
+	if _, err := parseList(`["a, b]`); err == nil {
+		t.Fatalf("Unclosed quote must fail list parsing")
+	}
+}
+
+func TestStringSlice(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var paths []string
+	fs.Var(&stringSliceValue{p: &paths}, "path", "")
+	paths = []string{"default"}
+
+	if err := fs.Parse([]string{"-path=a", "-path=b,c"}); err != nil {
+		t.Fatalf("Cannot parse flags: %s", err)
+	}
+	if strings.Join(paths, "|") != "a|b|c" {
+		t.Fatalf("Unexpected paths %q. Expected [a b c]", paths)
+	}
+
+	if err := setFlagValue(fs.Lookup("path"), `[x, "y,z"]`); err != nil {
+		t.Fatalf("Cannot set value from config: %s", err)
+	}
+	if strings.Join(paths, "|") != "x|y,z" {
+		t.Fatalf("Unexpected paths %q. Expected [x y,z]", paths)
+	}
+	if s := fs.Lookup("path").Value.String(); s != `[x, "y,z"]` {
+		t.Fatalf("Unexpected string representation %q", s)
+	}
+}
+
+func TestSetSliceMergeCLIAndConfig(t *testing.T) {
+	paths := StringSlice("mergePath", nil, "flag for TestSetSliceMergeCLIAndConfig")
+	defer func() {
+		sliceMergeCLIAndConfig = false
+		delete(cliSliceItems, "mergePath")
+	}()
+	parsed = false
+	SetSliceMergeCLIAndConfig(true)
+
+	// Simulate -mergePath=/c -mergePath=/d passed via command line.
+	cliSliceItems["mergePath"] = []string{"/c", "/d"}
+
+	if _, _, _, ok := applyFlagArgs([]FlagArg{{Key: "mergePath", Value: "[/a, /b]"}}); !ok {
+		t.Fatalf("Cannot apply config")
+	}
+	if strings.Join(*paths, "|") != "/a|/b|/c|/d" {
+		t.Fatalf("Unexpected paths %q. Expected [/a /b /c /d]", *paths)
+	}
+
+	// Reload must not duplicate command-line items.
+	oldFlagValues, _, _, ok := applyFlagArgs([]FlagArg{{Key: "mergePath", Value: "[/a, /b]"}})
+	if !ok {
+		t.Fatalf("Cannot apply config")
+	}
+	if len(oldFlagValues) != 0 {
+		t.Fatalf("Unexpected changed flags on reload: %v", oldFlagValues)
+	}
+	if strings.Join(*paths, "|") != "/a|/b|/c|/d" {
+		t.Fatalf("Unexpected paths %q after reload. Expected [/a /b /c /d]", *paths)
+	}
+}
@@ -0,0 +1,83 @@
+package iniflags
+
+import (
+	"flag"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	trackUsage  bool
+	readFlags   = make(map[string]bool)
+	readFlagsMu sync.Mutex
+)
+
+// SetTrackUsage enables tracking reads of flag values.
+//
+// Only reads via GetString(), GetBool(), GetInt() and the other Get*()
+// getters are tracked, since reads via pointers returned by flag.String()
+// and friends cannot be intercepted. Use UnreadFlags() or WarnUnreadFlags()
+// for obtaining configured flags, which were never read.
+func SetTrackUsage(track bool) {
+	if parsed {
+		logger.Panicf("iniflags: SetTrackUsage() must be called before Parse()")
+	}
+	trackUsage = track
+}
+
+// recordFlagRead marks the given flag or dynamic value as read
+// if usage tracking is enabled.
+func recordFlagRead(name string) {
+	if !trackUsage {
+		return
+	}
+	readFlagsMu.Lock()
+	readFlags[name] = true
+	readFlagsMu.Unlock()
+}
+
+// UnreadFlags returns sorted names of flags and dynamic values, which were
+// explicitly set via command line, environment variables, overrides
+// or config file, but were never read via the getters mentioned
+// in SetTrackUsage() docs.
+//
+// This helps finding stale config keys. It returns nil if usage tracking
+// isn't enabled via SetTrackUsage().
+func UnreadFlags() []string {
+	if !trackUsage {
+		return nil
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	readFlagsMu.Lock()
+	defer readFlagsMu.Unlock()
+
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if isFlagSet(f.Name) && !readFlags[f.Name] {
+			names = append(names, f.Name)
+		}
+	})
+	dynamicValuesMu.RLock()
+	for name := range dynamicValues {
+		if !readFlags[name] {
+			names = append(names, name)
+		}
+	}
+	dynamicValuesMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// WarnUnreadFlags logs a warning with the names returned by UnreadFlags()
+// if there are any.
+//
+// Call it on app shutdown, e.g. via defer in main(), for noticing stale
+// config keys. It does nothing if usage tracking isn't enabled via
+// SetTrackUsage().
+func WarnUnreadFlags() {
+	if names := UnreadFlags(); len(names) > 0 {
+		logger.Printf("iniflags: WARNING: the following configured flags were never read: %s", strings.Join(names, ", "))
+	}
+}
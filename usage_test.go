@@ -0,0 +1,50 @@
+package iniflags
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestUnreadFlags(t *testing.T) {
+	flag.String("usedFlag", "", "flag for TestUnreadFlags")
+	flag.String("staleFlag", "", "flag for TestUnreadFlags")
+	defer func() {
+		trackUsage = false
+		readFlags = make(map[string]bool)
+		configFlags = make(map[string]bool)
+	}()
+
+	parsed = false
+	SetTrackUsage(true)
+	configFlags = map[string]bool{"usedFlag": true, "staleFlag": true}
+	if _, err := GetString("usedFlag"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// The unread flags may also contain test.* flags set by go test.
+	unread := make(map[string]bool)
+	for _, name := range UnreadFlags() {
+		unread[name] = true
+	}
+	if !unread["staleFlag"] || unread["usedFlag"] {
+		t.Fatalf("Unexpected unread flags %v. Expected staleFlag without usedFlag", unread)
+	}
+
+	var logBuf bytes.Buffer
+	oldLogger := logger
+	defer SetLogger(oldLogger)
+	SetLogger(log.New(&logBuf, "", 0))
+	WarnUnreadFlags()
+	if logs := logBuf.String(); !strings.Contains(logs, "WARNING") || !strings.Contains(logs, "staleFlag") || strings.Contains(logs, "usedFlag") {
+		t.Fatalf("Unexpected warning for unread flags: %q", logs)
+	}
+
+	logBuf.Reset()
+	trackUsage = false
+	WarnUnreadFlags()
+	if logBuf.Len() > 0 {
+		t.Fatalf("Unexpected warning without usage tracking: %q", logBuf.String())
+	}
+}
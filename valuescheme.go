@@ -0,0 +1,105 @@
+package iniflags
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// ValueResolver returns the flag value for the given reference.
+//
+// The reference is the part of the value following "scheme://".
+type ValueResolver func(ref string) (string, error)
+
+var valueResolvers = make(map[string]ValueResolver)
+
+// RegisterValueScheme registers resolver for flag values in the form
+// "scheme://ref".
+//
+// Such values are resolved via resolver when read from config files
+// or environment variables. This allows storing references to secrets
+// in config files instead of secrets themselves.
+//
+// For example, RegisterValueScheme("vault", fetchFromVault) makes
+// "dbPassword = vault://db/password" call fetchFromVault("db/password").
+func RegisterValueScheme(scheme string, resolver ValueResolver) {
+	if parsed {
+		logger.Panicf("iniflags: RegisterValueScheme() must be called before Parse()")
+	}
+	valueResolvers[strings.ToLower(scheme)] = resolver
+}
+
+// resolveFlagValue prepares the raw value read from config
+// or environment variable for setting to the flag f.
+//
+// configPath is the path to the config the value is read from. It is empty
+// for values from environment variables and SetOverrides().
+func resolveFlagValue(f *flag.Flag, raw, configPath string) (string, error) {
+	return resolveFlagValueExt(f, raw, configPath, true)
+}
+
+// resolveFlagValueExt works like resolveFlagValue. If track is false,
+// referenced files aren't tracked for changes and flags aren't marked
+// as secret, so the value may be resolved without applying it.
+func resolveFlagValueExt(f *flag.Flag, raw, configPath string, track bool) (string, error) {
+	value := trimFlagValue(f, raw)
+	isRef := isFileRef(value)
+	var err error
+	if track {
+		value, err = resolveFileRef(f, value, configPath)
+	} else if isRef {
+		value, err = readFileRef(fileRefPath(value, configPath))
+	}
+	if err != nil {
+		return "", err
+	}
+	if !isRef {
+		// Contents of referenced files are used verbatim.
+		if track && secretValueSchemes[valueScheme(value)] {
+			// Mark the flag before resolving the value, so the resolved value
+			// is redacted in errors.
+			schemeSecretFlags.Store(f.Name, true)
+		}
+		if value, err = resolveValueScheme(value); err != nil {
+			return "", err
+		}
+	}
+	if flagKind(f) == KindFloat {
+		if value, err = fixDecimalComma(value); err != nil {
+			return "", err
+		}
+	}
+	if normalizer := typeNormalizers[flagKind(f)]; normalizer != nil {
+		if value, err = normalizer(value); err != nil {
+			return "", fmt.Errorf("cannot normalize %s value: %s", flagKind(f), err)
+		}
+	}
+	return value, nil
+}
+
+// valueScheme returns the lowercased scheme for the value in the form
+// "scheme://ref" or an empty string if the value has no scheme.
+func valueScheme(value string) string {
+	n := strings.Index(value, "://")
+	if n <= 0 {
+		return ""
+	}
+	return strings.ToLower(value[:n])
+}
+
+func resolveValueScheme(value string) (string, error) {
+	scheme := valueScheme(value)
+	if scheme == "" {
+		return value, nil
+	}
+	n := strings.Index(value, "://")
+	resolver, ok := valueResolvers[scheme]
+	if !ok {
+		return value, nil
+	}
+	resolved, err := resolver(value[n+3:])
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s: %s", value, err)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,57 @@
+package iniflags
+
+import (
+	"errors"
+	"flag"
+	"testing"
+)
+
+func TestRegisterValueScheme(t *testing.T) {
+	f := flag.Lookup("x")
+	defer delete(valueResolvers, "test")
+
+	parsed = false
+	RegisterValueScheme("test", func(ref string) (string, error) {
+		if ref == "missing" {
+			return "", errors.New("not found")
+		}
+		return "resolved-" + ref, nil
+	})
+
+	value, err := resolveFlagValue(f, "test://secret", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if value != "resolved-secret" {
+		t.Fatalf("Unexpected value [%s]. Expected [resolved-secret]", value)
+	}
+	if value, err = resolveFlagValue(f, "http://host/path", ""); err != nil || value != "http://host/path" {
+		t.Fatalf("Values with unregistered schemes must be left as is; got [%s], err=%v", value, err)
+	}
+	if _, err = resolveFlagValue(f, "test://missing", ""); err == nil {
+		t.Fatalf("Expecting error for unresolvable reference")
+	}
+}
+
+func TestSecretValueScheme(t *testing.T) {
+	f := flag.Lookup("x")
+	defer func() {
+		delete(valueResolvers, "testsecret")
+		delete(secretValueSchemes, "testsecret")
+		schemeSecretFlags.Delete("x")
+	}()
+	valueResolvers["testsecret"] = func(ref string) (string, error) {
+		return "hunter2", nil
+	}
+	secretValueSchemes["testsecret"] = true
+
+	if isSecretFlag("x") {
+		t.Fatalf("x mustn't be secret before resolving a secret value")
+	}
+	if _, err := resolveFlagValue(f, "testsecret://db", ""); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !isSecretFlag("x") || redactValue("x", "hunter2") != redactedValue {
+		t.Fatalf("Flags set via secret schemes must be treated as secret")
+	}
+}
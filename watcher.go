@@ -0,0 +1,64 @@
+package iniflags
+
+import (
+	"sync"
+	"time"
+)
+
+// WatcherStatus describes the state of goroutines watching for config
+// updates via -configUpdateInterval and SIGHUP.
+type WatcherStatus struct {
+	// Running is true if config updates are watched.
+	Running bool
+
+	// LastCheck is the time of the last config check.
+	LastCheck time.Time
+
+	// LastCheckDuration is the duration of the last config check.
+	LastCheckDuration time.Duration
+
+	// LastCheckError is the error occurred during the last config check.
+	// It wraps *ParseError for the first error found in the config
+	// if there is such an error.
+	//
+	// It is nil if the last check succeeded.
+	LastCheckError error
+
+	// NextCheck is the time of the next config check scheduled
+	// via -configUpdateInterval.
+	//
+	// It is zero if -configUpdateInterval isn't set.
+	NextCheck time.Time
+}
+
+var (
+	watcherStatus   WatcherStatus
+	watcherStatusMu sync.Mutex
+)
+
+// ConfigWatcherStatus returns a snapshot of the config watcher status.
+func ConfigWatcherStatus() WatcherStatus {
+	watcherStatusMu.Lock()
+	defer watcherStatusMu.Unlock()
+	return watcherStatus
+}
+
+func setWatcherRunning() {
+	watcherStatusMu.Lock()
+	watcherStatus.Running = true
+	watcherStatusMu.Unlock()
+}
+
+func setNextCheck(t time.Time) {
+	watcherStatusMu.Lock()
+	watcherStatus.NextCheck = t
+	watcherStatusMu.Unlock()
+}
+
+func recordConfigCheck(startTime time.Time, err error) {
+	watcherStatusMu.Lock()
+	watcherStatus.LastCheck = startTime
+	watcherStatus.LastCheckDuration = time.Since(startTime)
+	watcherStatus.LastCheckError = err
+	watcherStatusMu.Unlock()
+}
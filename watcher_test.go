@@ -0,0 +1,50 @@
+package iniflags
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestConfigWatcherStatus(t *testing.T) {
+	path := t.TempDir() + "/config.ini"
+	if err := os.WriteFile(path, []byte("x = foo\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	oldConfig := *config
+	oldX := *x
+	oldAllowUnknownFlags := *allowUnknownFlags
+	defer func() {
+		*config = oldConfig
+		*x = oldX
+		*allowUnknownFlags = oldAllowUnknownFlags
+	}()
+	*config = path
+	*allowUnknownFlags = false
+
+	parsed = true
+	TriggerReload()
+	status := ConfigWatcherStatus()
+	if status.LastCheck.IsZero() {
+		t.Fatalf("LastCheck must be set after reload")
+	}
+	if status.LastCheckError != nil {
+		t.Fatalf("Unexpected LastCheckError: %s", status.LastCheckError)
+	}
+
+	if err := os.WriteFile(path, []byte("x = foo\nunknown = bar\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %s", err)
+	}
+	TriggerReload()
+	status = ConfigWatcherStatus()
+	if status.LastCheckError == nil {
+		t.Fatalf("LastCheckError must be set after failed reload")
+	}
+	var pe *ParseError
+	if !errors.As(status.LastCheckError, &pe) {
+		t.Fatalf("LastCheckError %v must wrap *ParseError", status.LastCheckError)
+	}
+	if pe.FilePath != path || pe.LineNum != 2 || pe.Key != "unknown" {
+		t.Fatalf("Unexpected ParseError %+v", pe)
+	}
+}